@@ -0,0 +1,70 @@
+// Command xray-tail listens on the X-Ray daemon port and prints every segment
+// it receives as an indented tree, for viewing instrumentation locally without
+// running the daemon.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/goguardian/aws-xray-go/daemon"
+	"github.com/goguardian/aws-xray-go/utils"
+	"log"
+	"net"
+	"os"
+)
+
+const maxPacketSize = 64 * 1024
+
+func main() {
+	address := flag.String("address", defaultAddress(), "UDP address to listen on")
+	raw := flag.Bool("raw", false, "print the raw JSON document after each tree")
+	flag.Parse()
+
+	udpAddress, err := net.ResolveUDPAddr("udp", *address)
+	if err != nil {
+		log.Fatalf("invalid address: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddress)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	log.Println("Listening on:", conn.LocalAddr())
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Fatalf("failed to read: %v", err)
+		}
+
+		seg, err := daemon.ParsePacket(buf[:n])
+		if err != nil {
+			log.Printf("skipping packet: %v", err)
+			continue
+		}
+
+		render(os.Stdout, seg)
+
+		if *raw {
+			fmt.Fprintf(os.Stdout, "%s\n", seg.Raw)
+		}
+	}
+}
+
+// defaultAddress returns the daemon UDP address configured in the
+// environment, or the default address if it is invalid or a Unix datagram
+// socket, which cannot be listened on over UDP.
+func defaultAddress() string {
+	daemonAddress, err := utils.GetDaemonAddress()
+	if err != nil {
+		return utils.DefaultDaemonAddress
+	}
+
+	if network, _ := utils.DaemonNetwork(daemonAddress.UDP); network != "udp" {
+		return utils.DefaultDaemonAddress
+	}
+
+	return daemonAddress.UDP
+}
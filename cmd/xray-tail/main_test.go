@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/goguardian/aws-xray-go/utils"
+	"os"
+	"testing"
+)
+
+func TestDefaultAddress(t *testing.T) {
+	defer os.Unsetenv(utils.DaemonAddressEnv)
+
+	tests := []struct {
+		env    string
+		expect string
+	}{
+		{"127.0.0.1:3000", "127.0.0.1:3000"},
+		{"tcp:127.0.0.1:2000 udp:127.0.0.1:2001", "127.0.0.1:2001"},
+		{"unixgram:///var/run/xray.sock", utils.DefaultDaemonAddress},
+		{"tcp:127.0.0.1:2000 udp:unixgram:///var/run/xray.sock",
+			utils.DefaultDaemonAddress},
+		{"invalid", utils.DefaultDaemonAddress},
+	}
+
+	for _, test := range tests {
+		os.Setenv(utils.DaemonAddressEnv, test.env)
+
+		if result := defaultAddress(); result != test.expect {
+			t.Errorf("Expected address '%s' for '%s', got '%s'", test.expect,
+				test.env, result)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"github.com/goguardian/aws-xray-go/daemon"
+	"io"
+	"sort"
+	"strings"
+)
+
+// render writes the segment and its subsegments to w as an indented tree.
+func render(w io.Writer, seg *daemon.Document) {
+	kind := "segment"
+	if seg.Type == "subsegment" {
+		kind = "subsegment"
+	}
+
+	fmt.Fprintf(w, "%s %s (trace %s, id %s", kind, seg.Name, seg.TraceID, seg.ID)
+	if seg.ParentID != "" {
+		fmt.Fprintf(w, ", parent %s", seg.ParentID)
+	}
+	fmt.Fprintln(w, ")")
+
+	renderNode(w, seg, 1)
+
+	fmt.Fprintln(w)
+}
+
+func renderNode(w io.Writer, seg *daemon.Document, depth int) {
+	fields := []string{strings.Repeat("  ", depth-1) + "- " + seg.Name,
+		duration(seg)}
+
+	if flags := flags(seg); flags != "" {
+		fields = append(fields, flags)
+	}
+
+	if status := httpStatus(seg); status != "" {
+		fields = append(fields, "status="+status)
+	}
+
+	if annotations := annotations(seg); annotations != "" {
+		fields = append(fields, annotations)
+	}
+
+	fmt.Fprintln(w, strings.Join(fields, "  "))
+
+	for _, subseg := range seg.Subsegments {
+		renderNode(w, subseg, depth+1)
+	}
+}
+
+func duration(seg *daemon.Document) string {
+	if seg.InProgress || seg.EndTime == 0 {
+		return "in progress"
+	}
+
+	return fmt.Sprintf("%.1fms", (seg.EndTime-seg.StartTime)*1000)
+}
+
+func flags(seg *daemon.Document) string {
+	flags := []string{}
+	if seg.Fault {
+		flags = append(flags, "fault")
+	}
+	if seg.Error {
+		flags = append(flags, "error")
+	}
+	if seg.Throttle {
+		flags = append(flags, "throttle")
+	}
+
+	if len(flags) == 0 {
+		return ""
+	}
+
+	return "[" + strings.Join(flags, ",") + "]"
+}
+
+func httpStatus(seg *daemon.Document) string {
+	response, ok := seg.HTTP["response"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	status, ok := response["status"].(float64)
+	if !ok || status == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d", int(status))
+}
+
+func annotations(seg *daemon.Document) string {
+	keys := make([]string, 0, len(seg.Annotations))
+	for key := range seg.Annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, seg.Annotations[key]))
+	}
+
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"github.com/goguardian/aws-xray-go/daemon"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	packet := `{"format": "json", "version": 1}
+{"id": "1", "trace_id": "1-5759e988-bd862e3fe1be46a994272793", "name": "api",
+ "start_time": 10, "end_time": 10.25, "fault": true,
+ "http": {"response": {"status": 500}},
+ "annotations": {"user": "alice", "count": 2},
+ "subsegments": [
+   {"id": "2", "name": "db", "start_time": 10, "end_time": 10.1, "error": true,
+    "throttle": true,
+    "subsegments": [{"id": "3", "name": "query", "start_time": 10}]}
+ ]}`
+
+	seg, err := daemon.ParsePacket([]byte(packet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	render(&buf, seg)
+
+	expect := []string{
+		"segment api (trace 1-5759e988-bd862e3fe1be46a994272793, id 1)",
+		"- api  250.0ms  [fault]  status=500  count=2 user=alice",
+		"  - db  100.0ms  [error,throttle]",
+		"    - query  in progress",
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expect) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(expect), len(lines),
+			buf.String())
+	}
+
+	for i, line := range lines {
+		if line != expect[i] {
+			t.Errorf("Expected line %d to be %q, got %q", i, expect[i], line)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package daemon decodes documents sent in the X-Ray daemon protocol.
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Document represents a segment or subsegment document received by the
+// daemon.
+type Document struct {
+	ID          string                            `json:"id"`
+	TraceID     string                            `json:"trace_id"`
+	ParentID    string                            `json:"parent_id"`
+	Type        string                            `json:"type"`
+	Name        string                            `json:"name"`
+	Namespace   string                            `json:"namespace"`
+	StartTime   float64                           `json:"start_time"`
+	EndTime     float64                           `json:"end_time"`
+	InProgress  bool                              `json:"in_progress"`
+	Fault       bool                              `json:"fault"`
+	Error       bool                              `json:"error"`
+	Throttle    bool                              `json:"throttle"`
+	Annotations map[string]interface{}            `json:"annotations"`
+	Metadata    map[string]map[string]interface{} `json:"metadata"`
+	HTTP        map[string]interface{}            `json:"http"`
+	Subsegments []*Document                       `json:"subsegments"`
+	Raw         []byte                            `json:"-"`
+}
+
+// ParsePacket decodes a packet in the daemon protocol, a header line followed
+// by a JSON document.
+func ParsePacket(packet []byte) (*Document, error) {
+	parts := bytes.SplitN(packet, []byte("\n"), 2)
+	if len(parts) != 2 {
+		return nil, errors.New("packet is missing protocol header")
+	}
+
+	header := map[string]interface{}{}
+	if err := json.Unmarshal(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("error decoding protocol header: %s", err.Error())
+	}
+
+	if header["format"] != "json" || header["version"] != float64(1) {
+		return nil, fmt.Errorf("unexpected protocol header: %s", parts[0])
+	}
+
+	doc := &Document{}
+	if err := json.Unmarshal(parts[1], doc); err != nil {
+		return nil, fmt.Errorf("error decoding segment: %s", err.Error())
+	}
+	doc.Raw = append([]byte{}, parts[1]...)
+
+	return doc, nil
+}
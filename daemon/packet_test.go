@@ -0,0 +1,42 @@
+package daemon
+
+import "testing"
+
+func TestParsePacket(t *testing.T) {
+	tests := []struct {
+		packet      string
+		expectError bool
+	}{
+		{packet: "{\"format\": \"json\", \"version\": 1}\n{\"name\": \"segment\", \"subsegments\": [{\"name\": \"child\"}]}"},
+		{packet: "{\"name\": \"segment\"}", expectError: true},
+		{packet: "{\"format\": \"xml\", \"version\": 1}\n{}", expectError: true},
+		{packet: "{\"format\": \"json\", \"version\": 1}\n{", expectError: true},
+	}
+
+	for _, test := range tests {
+		doc, err := ParsePacket([]byte(test.packet))
+		if test.expectError {
+			if err == nil {
+				t.Errorf("Expected error parsing %q", test.packet)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %s", test.packet, err)
+			continue
+		}
+
+		if doc.Name != "segment" {
+			t.Errorf("Expected name 'segment', got '%s'", doc.Name)
+		}
+
+		if len(doc.Subsegments) != 1 || doc.Subsegments[0].Name != "child" {
+			t.Error("Expected subsegment 'child'")
+		}
+
+		if len(doc.Raw) == 0 {
+			t.Error("Expected the raw document")
+		}
+	}
+}
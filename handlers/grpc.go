@@ -2,13 +2,11 @@ package handlers
 
 import (
 	"errors"
-	"fmt"
+	"github.com/goguardian/aws-xray-go/sampling"
 	"github.com/goguardian/aws-xray-go/segment"
 	"github.com/goguardian/aws-xray-go/utils"
-	"sync"
 	"time"
 
-	"github.com/patrickmn/go-cache"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -18,26 +16,13 @@ const (
 	mdParentKey  = "xray-parentid"
 	mdRootKey    = "xray-rootid"
 	mdSampledKey = "xray-sampled"
-	mdSegmentKey = "xray-segment"
 )
 
-var (
-	segmentCache          *cache.Cache
-	cacheCleanupFrequency = 30 * time.Second
-	cacheDuration         = 10 * time.Minute
-	cacheDurationMutex    = &sync.RWMutex{}
-)
-
-func init() {
-	segmentCache = cache.New(cache.NoExpiration, cacheCleanupFrequency)
-}
-
-// SetSegmentCacheDuration updates the duration of segments in in-memory cache
-func SetSegmentCacheDuration(duration time.Duration) {
-	cacheDurationMutex.Lock()
-	defer cacheDurationMutex.Unlock()
-	cacheDuration = duration
-}
+// SetSegmentCacheDuration has no effect.  Segments are carried in the context
+// instead of an in-memory cache.
+//
+// Deprecated: segments are no longer cached.
+func SetSegmentCacheDuration(duration time.Duration) {}
 
 // NewGRPCClientConn creates a new gRPC client connection with a unary
 // interceptor that performs traces of gRPC requests.
@@ -69,7 +54,7 @@ func GRPCClientUnaryInterceptor(
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 
-	subseg := seg.AddNewSubsegment(method)
+	subseg := seg.AddNewNestedSubsegment(ctx, method)
 	subseg.AddRemote()
 
 	sampled := "0"
@@ -77,11 +62,17 @@ func GRPCClientUnaryInterceptor(
 		sampled = "1"
 	}
 
-	mdctx := metadata.NewContext(ctx, metadata.New(map[string]string{
+	md := metadata.New(map[string]string{
 		mdRootKey:    seg.TraceID,
 		mdParentKey:  subseg.ID,
 		mdSampledKey: sampled,
-	}))
+	})
+	utils.InjectTraceHeader(utils.MetadataCarrier(md),
+		seg.DownstreamHeader(subseg.ID))
+
+	mdctx := metadata.NewContext(ctx, md)
+
+	mdctx = segment.NewSubsegmentContext(mdctx, subseg)
 
 	err = invoker(mdctx, method, req, reply, cc, opts...)
 
@@ -106,7 +97,10 @@ func GRPCServerUnaryInterceptor(name string) func(
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
 
-		seg := segment.New(name, ctx)
+		samplingCtx := sampling.NewContext(contextFromMetadata(ctx),
+			&sampling.Request{URLPath: info.FullMethod})
+
+		seg := segment.New(name, samplingCtx)
 		ctx = AddSegmentToContext(seg, ctx)
 		defer seg.Close()
 
@@ -114,51 +108,34 @@ func GRPCServerUnaryInterceptor(name string) func(
 	}
 }
 
-// AddSegmentToContext adds a segment reference to a context.Context instance.
-func AddSegmentToContext(seg *segment.Segment, ctx context.Context) context.Context {
-	sampled := "0"
-	if seg.Traced {
-		sampled = "1"
+// contextFromMetadata extracts the trace header of a gRPC request with the
+// configured propagators and returns a new context carrying the trace header.
+// Requests without a valid trace header fall back to the X-Ray metadata keys.
+func contextFromMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return ctx
 	}
 
-	newctx := metadata.NewContext(ctx, metadata.MD{
-		mdParentKey:  []string{seg.ParentID},
-		mdRootKey:    []string{seg.TraceID},
-		mdSampledKey: []string{sampled},
-		mdSegmentKey: []string{seg.TraceID},
-	})
-
-	cacheDurationMutex.RLock()
-	defer cacheDurationMutex.RUnlock()
+	header, err := utils.ExtractTraceHeader(utils.MetadataCarrier(md))
+	if err != nil {
+		return ctx
+	}
 
-	segmentCache.Add(seg.TraceID, seg, cacheDuration)
+	return utils.NewTraceHeaderContext(ctx, header)
+}
 
-	return newctx
+// AddSegmentToContext adds a segment reference to a context.Context instance.
+func AddSegmentToContext(seg *segment.Segment, ctx context.Context) context.Context {
+	return segment.NewContext(ctx, seg)
 }
 
 // GetSegmentFromContext retrieves a segment from a context.Context instance.
 func GetSegmentFromContext(ctx context.Context) (*segment.Segment, error) {
-	md, ok := metadata.FromContext(ctx)
+	seg, ok := segment.FromContext(ctx)
 	if !ok {
-		return nil, errors.New("Unable to load metadata from context")
-	}
-
-	str := md[mdSegmentKey]
-	if len(str) < 1 || len(str[0]) < 1 {
 		return nil, errors.New("Segment not found in context")
 	}
 
-	segmentTraceID := str[0]
-
-	result, found := segmentCache.Get(segmentTraceID)
-	if !found {
-		return nil, fmt.Errorf("Segment not found in cache: %s", segmentTraceID)
-	}
-
-	seg, ok := result.(*segment.Segment)
-	if !ok {
-		return nil, errors.New("Unable to assert type for segment from cache")
-	}
-
 	return seg, nil
 }
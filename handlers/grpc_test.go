@@ -1,14 +1,13 @@
 package handlers
 
 import (
-	"bytes"
 	"github.com/goguardian/aws-xray-go/segment"
+	"github.com/goguardian/aws-xray-go/utils"
 	"testing"
-	"time"
-
-	"google.golang.org/grpc/metadata"
 
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestAddSegmentToContext(t *testing.T) {
@@ -40,27 +39,84 @@ func TestGetSegmentFromContext(t *testing.T) {
 		t.Error("A context with no segment should error")
 	}
 
-	ctx = metadata.NewContext(context.TODO(), metadata.New(map[string]string{
-		mdSegmentKey: "",
-	}))
+	first := segment.New("first", nil)
+	second := segment.New("second", nil)
+	second.TraceID = first.TraceID
 
-	_, err = GetSegmentFromContext(ctx)
-	if err == nil {
-		t.Error("A context with no segment metadata key should error")
+	firstCtx := AddSegmentToContext(first, context.TODO())
+	secondCtx := AddSegmentToContext(second, context.TODO())
+
+	if seg, _ := GetSegmentFromContext(firstCtx); seg != first {
+		t.Error("Segments sharing a trace ID should not overwrite each other")
+	}
+
+	if seg, _ := GetSegmentFromContext(secondCtx); seg != second {
+		t.Error("Segments sharing a trace ID should not overwrite each other")
 	}
+}
 
-	ctx = metadata.NewContext(context.TODO(), metadata.New(map[string]string{
-		mdSegmentKey: "123",
-	}))
+func TestGRPCB3Propagation(t *testing.T) {
+	defer utils.SetPropagators(utils.XRayPropagator{})
+	utils.SetPropagators(utils.XRayPropagator{}, utils.B3Propagator{})
 
-	_, err = GetSegmentFromContext(ctx)
-	if err == nil {
-		t.Error("A context with a segment key not found in cache should error")
+	ctx := metadata.NewContext(context.Background(), metadata.New(
+		map[string]string{
+			"x-b3-traceid": "5759e988bd862e3fe1be46a994272793",
+			"x-b3-spanid":  "53995c3f42cd8ad8",
+			"x-b3-sampled": "1",
+		}))
+
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+
+		outgoing, _ = metadata.FromContext(ctx)
+		return nil
 	}
 
-	segmentCache.Add("123", bytes.Buffer{}, 1*time.Minute)
-	_, err = GetSegmentFromContext(ctx)
-	if err == nil {
-		t.Error("A bad cache entry should not be able to be type asserted")
+	var seg *segment.Segment
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		seg, _ = GetSegmentFromContext(ctx)
+		return nil, GRPCClientUnaryInterceptor(ctx, "/demo.Demo/Call", req,
+			nil, nil, invoker)
+	}
+
+	GRPCServerUnaryInterceptor("server")(ctx, nil,
+		&grpc.UnaryServerInfo{}, handler)
+
+	if seg.TraceID != "1-5759e988-bd862e3fe1be46a994272793" ||
+		seg.ParentID != "53995c3f42cd8ad8" || !seg.Traced {
+		t.Errorf("Segment should continue the B3 trace, got trace ID '%s' "+
+			"and parent ID '%s'", seg.TraceID, seg.ParentID)
+	}
+
+	carrier := utils.MetadataCarrier(outgoing)
+	if carrier.Get(utils.B3TraceIDHeader) != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("Outgoing metadata should include B3 headers, got %v", outgoing)
+	}
+
+	if carrier.Get(utils.XRayHeader) == "" || carrier.Get(mdRootKey) == "" {
+		t.Errorf("Outgoing metadata should include X-Ray headers, got %v",
+			outgoing)
+	}
+}
+
+func TestGRPCClientNesting(t *testing.T) {
+	seg := segment.New("segment", nil)
+	parent := seg.AddNewSubsegment("parent")
+	ctx := segment.NewSubsegmentContext(AddSegmentToContext(seg,
+		context.Background()), parent)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+
+		return nil
+	}
+
+	GRPCClientUnaryInterceptor(ctx, "/demo.Demo/Call", nil, nil, nil, invoker)
+
+	if len(seg.Subsegments) != 1 || len(parent.Subsegments) != 1 ||
+		parent.Subsegments[0].Name != "/demo.Demo/Call" {
+		t.Error("Call subsegment should be nested under the context subsegment")
 	}
 }
@@ -29,10 +29,11 @@ func NewHTTPInterceptor(seg *segment.Segment) http.RoundTripper {
 }
 
 // RoundTrip implements http.RoundTripper interface.  Adds a new subsegment,
-// adds trace header to HTTP requests, performs the requests, and records
-// remote response data.
+// nested under the current subsegment of the request context, adds trace
+// header to HTTP requests, performs the requests, and records remote response
+// data.
 func (h HTTPInterceptor) RoundTrip(req *http.Request) (*http.Response, error) {
-	subseg := h.segment.AddNewSubsegment(req.URL.Host)
+	subseg := h.segment.AddNewNestedSubsegment(req.Context(), req.URL.Host)
 	subseg.AddRemote()
 
 	addXRayHeader(req, subseg)
@@ -80,21 +81,17 @@ func (h HTTPInterceptor) RoundTrip(req *http.Request) (*http.Response, error) {
 	return res, err
 }
 
-// addXRayHeader adds X-Ray trace header to an HTTP request.
+// addXRayHeader adds the trace header to an HTTP request with the configured
+// propagators.
 func addXRayHeader(req *http.Request, subseg *segment.Subsegment) {
-	traceID := ""
-	sampled := "0"
+	header := &utils.TraceHeader{Parent: subseg.ID, Sampled: "0"}
 	if subseg.Segment != nil {
-		traceID = subseg.Segment.TraceID
-		if subseg.Segment.Traced {
-			sampled = "1"
-		}
+		header = subseg.Segment.DownstreamHeader(subseg.ID)
 	}
 
 	if req.Header == nil {
 		req.Header = http.Header{}
 	}
 
-	req.Header.Add(utils.XRayHeader, fmt.Sprintf(
-		"Root=%s; Parent=%s; Sampled=%s", traceID, subseg.ID, sampled))
+	utils.InjectTraceHeader(utils.HTTPHeaderCarrier(req.Header), header)
 }
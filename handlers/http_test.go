@@ -1,23 +1,53 @@
 package handlers
 
 import (
+	"context"
 	"github.com/goguardian/aws-xray-go/segment"
 	"github.com/goguardian/aws-xray-go/utils"
+	"github.com/goguardian/aws-xray-go/xraytest"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewHTTPClient(t *testing.T) {
+	daemon := xraytest.StartDaemon(t)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(utils.XRayHeader) == "" {
+				t.Errorf("HTTP request header '%s' should be set", utils.XRayHeader)
+			}
+			w.WriteHeader(http.StatusTeapot)
+		}))
+	defer server.Close()
+
 	seg := segment.New("segment", nil)
+	seg.Traced = true
 
 	client := NewHTTPClient(seg)
 	if client == nil {
 		t.Error("HTTP Client should not be nil")
 	}
 
-	_, err := client.Get("https://aws.amazon.com")
+	_, err := client.Get(server.URL)
+	if err == nil {
+		t.Error("A 4XX response should error")
+	}
+
+	seg.Close()
+
+	received, err := daemon.WaitForSegment("segment", 5*time.Second)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	subseg := xraytest.AssertSubsegment(t, received, host)
+	if subseg != nil && !subseg.Error {
+		t.Error("Subsegment should be marked error")
 	}
 }
 
@@ -36,3 +66,47 @@ func TestAddXRayHeader(t *testing.T) {
 		t.Errorf("HTTP request header '%s' should be set", utils.XRayHeader)
 	}
 }
+
+func TestAddXRayHeaderForwarding(t *testing.T) {
+	incoming := &http.Request{
+		Header: http.Header{
+			utils.XRayHeader: []string{"Self=1-67891234-12456789abcdef012345678;" +
+				"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;" +
+				"Sampled=1;Lineage=a87bd80c:0;CalledFrom=ALB"},
+		},
+	}
+
+	seg := segment.New("segment", utils.ContextFromHeaders(incoming))
+	subseg := seg.AddNewSubsegment("subsegment")
+
+	req := &http.Request{}
+	addXRayHeader(req, subseg)
+
+	expected := "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=" + subseg.ID +
+		";Sampled=1;Lineage=a87bd80c:0;CalledFrom=ALB"
+	if header := req.Header.Get(utils.XRayHeader); header != expected {
+		t.Errorf("Expected header '%s', got '%s'", expected, header)
+	}
+}
+
+func TestHTTPInterceptorNesting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	seg := segment.New("segment", nil)
+	parent := seg.AddNewSubsegment("parent")
+	ctx := segment.NewSubsegmentContext(segment.NewContext(
+		context.Background(), seg), parent)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := NewHTTPClient(seg).Do(req.WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	if len(seg.Subsegments) != 1 || len(parent.Subsegments) != 1 ||
+		parent.Subsegments[0].Name != host {
+		t.Error("Request subsegment should be nested under the context subsegment")
+	}
+}
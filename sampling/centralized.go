@@ -0,0 +1,374 @@
+package sampling
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/goguardian/aws-xray-go/utils"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRulesInterval   = 5 * time.Minute
+	defaultTargetsInterval = 10 * time.Second
+	rulesTTL               = 1 * time.Hour
+	rulesPath              = "/GetSamplingRules"
+	targetsPath            = "/SamplingTargets"
+)
+
+// CentralizedConfig represents the configuration of a CentralizedStrategy.
+// Zero values are replaced with defaults.
+type CentralizedConfig struct {
+	// Endpoint is the base URL of the X-Ray API.  It defaults to the TCP
+	// address of the daemon, which proxies requests to X-Ray.
+	Endpoint string
+	// RulesInterval is how often sampling rules are fetched.
+	RulesInterval time.Duration
+	// TargetsInterval is how often statistics are reported and sampling
+	// targets are fetched.  Rules assigned a longer interval by X-Ray are
+	// reported when it has elapsed.
+	TargetsInterval time.Duration
+	// Fallback decides requests while sampling rules are unavailable.  It
+	// defaults to tracing the first request each second and five percent of
+	// other requests.
+	Fallback Strategy
+	// HTTPClient is the client used to send requests.
+	HTTPClient *http.Client
+	// ErrorHandler, if set, is called with errors from polling, and with the
+	// error if the daemon address in the environment is not valid.
+	ErrorHandler func(err error)
+}
+
+// CentralizedStrategy traces requests according to the sampling rules
+// configured in X-Ray.  Rules are polled from GetSamplingRules, and the
+// statistics of each rule are reported to GetSamplingTargets, which assigns
+// the reservoir quotas shared by all instances of a service.  The fallback
+// strategy is used until rules are fetched, and when they have not been
+// refreshed for an hour.
+type CentralizedStrategy struct {
+	config    CentralizedConfig
+	clientID  string
+	rules     []*centralizedRule
+	rulesAt   time.Time
+	done      chan struct{}
+	stopped   chan struct{}
+	refreshes chan struct{}
+	closeOnce sync.Once
+	now       func() time.Time
+
+	sync.RWMutex
+}
+
+type getSamplingRulesInput struct {
+	NextToken string `json:"NextToken,omitempty"`
+}
+
+type getSamplingRulesOutput struct {
+	SamplingRuleRecords []struct {
+		SamplingRule samplingRule `json:"SamplingRule"`
+	} `json:"SamplingRuleRecords"`
+	NextToken string `json:"NextToken"`
+}
+
+type samplingStatistics struct {
+	RuleName     string  `json:"RuleName"`
+	ClientID     string  `json:"ClientID"`
+	Timestamp    float64 `json:"Timestamp"`
+	RequestCount int     `json:"RequestCount"`
+	SampledCount int     `json:"SampledCount"`
+	BorrowCount  int     `json:"BorrowCount"`
+}
+
+type getSamplingTargetsInput struct {
+	SamplingStatisticsDocuments []samplingStatistics `json:"SamplingStatisticsDocuments"`
+}
+
+type samplingTarget struct {
+	RuleName          string   `json:"RuleName"`
+	FixedRate         float64  `json:"FixedRate"`
+	ReservoirQuota    *int     `json:"ReservoirQuota"`
+	ReservoirQuotaTTL *float64 `json:"ReservoirQuotaTTL"`
+	Interval          *int     `json:"Interval"`
+}
+
+type getSamplingTargetsOutput struct {
+	SamplingTargetDocuments []samplingTarget `json:"SamplingTargetDocuments"`
+	LastRuleModification    *float64         `json:"LastRuleModification"`
+}
+
+// NewCentralizedStrategy creates a new CentralizedStrategy and starts polling
+// for sampling rules and targets.
+func NewCentralizedStrategy(config CentralizedConfig) *CentralizedStrategy {
+	var addressErr error
+	if config.Endpoint == "" {
+		daemonAddress, err := utils.GetDaemonAddress()
+		if err != nil {
+			addressErr = fmt.Errorf("%s, using %s", err.Error(),
+				utils.DefaultDaemonAddress)
+			daemonAddress = &utils.DaemonAddress{TCP: utils.DefaultDaemonAddress}
+		}
+
+		config.Endpoint = "http://" + daemonAddress.TCP
+	}
+
+	if config.RulesInterval <= 0 {
+		config.RulesInterval = defaultRulesInterval
+	}
+
+	if config.TargetsInterval <= 0 {
+		config.TargetsInterval = defaultTargetsInterval
+	}
+
+	if config.Fallback == nil {
+		config.Fallback = NewSamplerStrategy(1, 0.05)
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	clientID := make([]byte, 12)
+	rand.Read(clientID)
+
+	s := &CentralizedStrategy{
+		config:    config,
+		clientID:  fmt.Sprintf("%x", clientID),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		refreshes: make(chan struct{}, 1),
+		now:       time.Now,
+	}
+
+	s.handleError(addressErr)
+
+	go s.run()
+
+	return s
+}
+
+// ShouldTrace returns whether the request is traced according to the first
+// matching sampling rule, in order of priority.
+func (s *CentralizedStrategy) ShouldTrace(request *Request) bool {
+	if request == nil {
+		request = &Request{}
+	}
+
+	now := s.now()
+
+	s.RLock()
+	rules := s.rules
+	expired := now.Sub(s.rulesAt) > rulesTTL
+	s.RUnlock()
+
+	if len(rules) == 0 || expired {
+		return s.config.Fallback.ShouldTrace(request)
+	}
+
+	for _, rule := range rules {
+		if rule.matches(request) {
+			return rule.sample(now)
+		}
+	}
+
+	return s.config.Fallback.ShouldTrace(request)
+}
+
+// Close stops polling.
+func (s *CentralizedStrategy) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	<-s.stopped
+}
+
+func (s *CentralizedStrategy) run() {
+	defer close(s.stopped)
+
+	s.handleError(s.refreshRules(context.Background()))
+
+	rulesTicker := time.NewTicker(s.config.RulesInterval)
+	defer rulesTicker.Stop()
+
+	targetsTicker := time.NewTicker(s.config.TargetsInterval)
+	defer targetsTicker.Stop()
+
+	for {
+		select {
+		case <-rulesTicker.C:
+			s.handleError(s.refreshRules(context.Background()))
+		case <-s.refreshes:
+			s.handleError(s.refreshRules(context.Background()))
+		case <-targetsTicker.C:
+			s.handleError(s.refreshTargets(context.Background()))
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *CentralizedStrategy) handleError(err error) {
+	if err != nil && s.config.ErrorHandler != nil {
+		s.config.ErrorHandler(err)
+	}
+}
+
+// refreshRules fetches all sampling rules, keeping the reservoirs and
+// statistics of rules that already exist.
+func (s *CentralizedStrategy) refreshRules(ctx context.Context) error {
+	fetched := []samplingRule{}
+
+	input := getSamplingRulesInput{}
+	for {
+		output := getSamplingRulesOutput{}
+		if err := s.post(ctx, rulesPath, input, &output); err != nil {
+			return fmt.Errorf("error getting sampling rules: %s", err.Error())
+		}
+
+		for _, record := range output.SamplingRuleRecords {
+			if record.SamplingRule.supported() {
+				fetched = append(fetched, record.SamplingRule)
+			}
+		}
+
+		if output.NextToken == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	s.RLock()
+	existing := map[string]*centralizedRule{}
+	for _, rule := range s.rules {
+		existing[rule.RuleName] = rule
+	}
+	s.RUnlock()
+
+	rules := make([]*centralizedRule, 0, len(fetched))
+	for _, samplingRule := range fetched {
+		rule, ok := existing[samplingRule.RuleName]
+		if ok {
+			rule.update(samplingRule)
+		} else {
+			rule = newCentralizedRule(samplingRule)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+
+		return rules[i].RuleName < rules[j].RuleName
+	})
+
+	s.Lock()
+	s.rules = rules
+	s.rulesAt = s.now()
+	s.Unlock()
+
+	return nil
+}
+
+// refreshTargets reports the statistics of each rule due to be reported and
+// applies the sampling targets returned.  Rules are refreshed when they have
+// been modified since they were fetched.
+func (s *CentralizedStrategy) refreshTargets(ctx context.Context) error {
+	s.RLock()
+	rules := s.rules
+	rulesAt := s.rulesAt
+	s.RUnlock()
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	now := s.now()
+	input := getSamplingTargetsInput{}
+	byName := map[string]*centralizedRule{}
+
+	for _, rule := range rules {
+		if !rule.due(now) {
+			continue
+		}
+
+		requests, sampled, borrowed := rule.statistics()
+		byName[rule.RuleName] = rule
+
+		input.SamplingStatisticsDocuments = append(
+			input.SamplingStatisticsDocuments, samplingStatistics{
+				RuleName:     rule.RuleName,
+				ClientID:     s.clientID,
+				Timestamp:    float64(now.Unix()),
+				RequestCount: requests,
+				SampledCount: sampled,
+				BorrowCount:  borrowed,
+			})
+	}
+
+	if len(byName) == 0 {
+		return nil
+	}
+
+	output := getSamplingTargetsOutput{}
+	if err := s.post(ctx, targetsPath, input, &output); err != nil {
+		return fmt.Errorf("error getting sampling targets: %s", err.Error())
+	}
+
+	for _, target := range output.SamplingTargetDocuments {
+		if rule, ok := byName[target.RuleName]; ok {
+			rule.applyTarget(target, now)
+		}
+	}
+
+	if output.LastRuleModification != nil &&
+		epochTime(*output.LastRuleModification).After(rulesAt) {
+		select {
+		case s.refreshes <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// post sends a JSON request to the X-Ray API and decodes the response.
+func (s *CentralizedStrategy) post(
+	ctx context.Context,
+	path string,
+	input interface{},
+	output interface{},
+) error {
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.Endpoint+path,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(output)
+}
@@ -0,0 +1,350 @@
+package sampling
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/goguardian/aws-xray-go/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeXRay is a stand-in for the sampling APIs proxied by the daemon.
+type fakeXRay struct {
+	rules        []samplingRule
+	targets      getSamplingTargetsOutput
+	ruleRequests int
+	statistics   []samplingStatistics
+
+	sync.Mutex
+}
+
+func (f *fakeXRay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	switch r.URL.Path {
+	case rulesPath:
+		input := getSamplingRulesInput{}
+		json.NewDecoder(r.Body).Decode(&input)
+		f.ruleRequests++
+
+		// Rules are returned one per page.
+		output := getSamplingRulesOutput{}
+		page := 0
+		if input.NextToken != "" {
+			json.Unmarshal([]byte(input.NextToken), &page)
+		}
+
+		if page < len(f.rules) {
+			output.SamplingRuleRecords = append(output.SamplingRuleRecords,
+				struct {
+					SamplingRule samplingRule `json:"SamplingRule"`
+				}{f.rules[page]})
+		}
+
+		if page+1 < len(f.rules) {
+			next, _ := json.Marshal(page + 1)
+			output.NextToken = string(next)
+		}
+
+		json.NewEncoder(w).Encode(output)
+	case targetsPath:
+		input := getSamplingTargetsInput{}
+		json.NewDecoder(r.Body).Decode(&input)
+		f.statistics = append(f.statistics, input.SamplingStatisticsDocuments...)
+
+		json.NewEncoder(w).Encode(f.targets)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeXRay) getRuleRequests() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.ruleRequests
+}
+
+// constantStrategy is a strategy that always makes the same decision.
+type constantStrategy bool
+
+func (s constantStrategy) ShouldTrace(request *Request) bool {
+	return bool(s)
+}
+
+// testClock is a settable time source.
+type testClock struct {
+	now time.Time
+
+	sync.Mutex
+}
+
+func (c *testClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = now
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestRule(name string, priority int, urlPath string) samplingRule {
+	return samplingRule{
+		RuleName:    name,
+		Priority:    priority,
+		ServiceName: "*",
+		ServiceType: "*",
+		Host:        "*",
+		HTTPMethod:  "*",
+		URLPath:     urlPath,
+		ResourceARN: "*",
+		Version:     1,
+	}
+}
+
+func TestCentralizedStrategyRules(t *testing.T) {
+	health := newTestRule("health", 1, "/health")
+	unsupported := newTestRule("unsupported", 0, "*")
+	unsupported.Attributes = map[string]string{"key": "value"}
+	defaultRule := newTestRule("Default", 10000, "*")
+	defaultRule.FixedRate = 1
+
+	fake := &fakeXRay{rules: []samplingRule{defaultRule, unsupported, health}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	strategy := NewCentralizedStrategy(CentralizedConfig{
+		Endpoint:        server.URL,
+		RulesInterval:   time.Hour,
+		TargetsInterval: time.Hour,
+		Fallback:        constantStrategy(false),
+	})
+	defer strategy.Close()
+
+	waitFor(t, func() bool {
+		strategy.RLock()
+		defer strategy.RUnlock()
+		return len(strategy.rules) > 0
+	})
+
+	strategy.RLock()
+	names := []string{}
+	for _, rule := range strategy.rules {
+		names = append(names, rule.RuleName)
+	}
+	strategy.RUnlock()
+
+	if len(names) != 2 || names[0] != "health" || names[1] != "Default" {
+		t.Errorf("Expected rules 'health' and 'Default', got '%v'", names)
+	}
+
+	if strategy.ShouldTrace(&Request{URLPath: "/health"}) {
+		t.Error("Health checks should not be traced")
+	}
+
+	if !strategy.ShouldTrace(&Request{URLPath: "/users"}) {
+		t.Error("Other requests should be traced by the default rule")
+	}
+
+	// Rules that have not been refreshed for an hour are not used.
+	now := time.Now()
+	strategy.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if strategy.ShouldTrace(&Request{URLPath: "/users"}) {
+		t.Error("Expired rules should use the fallback")
+	}
+}
+
+func TestCentralizedStrategyTargets(t *testing.T) {
+	defaultRule := newTestRule("Default", 10000, "*")
+	defaultRule.ReservoirSize = 1
+
+	fake := &fakeXRay{rules: []samplingRule{defaultRule}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	strategy := NewCentralizedStrategy(CentralizedConfig{
+		Endpoint:        server.URL,
+		RulesInterval:   time.Hour,
+		TargetsInterval: time.Hour,
+		Fallback:        constantStrategy(false),
+	})
+	defer strategy.Close()
+
+	waitFor(t, func() bool { return fake.getRuleRequests() == 1 })
+	waitFor(t, func() bool {
+		strategy.RLock()
+		defer strategy.RUnlock()
+		return len(strategy.rules) == 1
+	})
+
+	now := time.Now()
+	clock := &testClock{now: now}
+	strategy.now = clock.Now
+
+	for i := 0; i < 5; i++ {
+		strategy.ShouldTrace(&Request{})
+	}
+
+	fake.Lock()
+	fake.targets = getSamplingTargetsOutput{
+		SamplingTargetDocuments: []samplingTarget{{
+			RuleName:          "Default",
+			FixedRate:         0,
+			ReservoirQuota:    intPtr(3),
+			ReservoirQuotaTTL: floatPtr(float64(now.Add(time.Minute).Unix())),
+			Interval:          intPtr(60),
+		}},
+		LastRuleModification: floatPtr(float64(now.Add(time.Second).Unix())),
+	}
+	fake.Unlock()
+
+	if err := strategy.refreshTargets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Lock()
+	statistics := fake.statistics
+	fake.Unlock()
+
+	if len(statistics) != 1 {
+		t.Fatalf("Expected 1 statistics document, got %d", len(statistics))
+	}
+
+	expected := samplingStatistics{
+		RuleName:     "Default",
+		ClientID:     strategy.clientID,
+		Timestamp:    float64(now.Unix()),
+		RequestCount: 5,
+		SampledCount: 1,
+		BorrowCount:  1,
+	}
+	if statistics[0] != expected {
+		t.Errorf("Expected statistics '%+v', got '%+v'", expected,
+			statistics[0])
+	}
+
+	if len(strategy.clientID) != 24 {
+		t.Errorf("Expected a 24 character client ID, got '%s'",
+			strategy.clientID)
+	}
+
+	// The quota applies from the next second.
+	clock.Set(now.Add(time.Second))
+
+	sampled := 0
+	for i := 0; i < 10; i++ {
+		if strategy.ShouldTrace(&Request{}) {
+			sampled++
+		}
+	}
+
+	if sampled != 3 {
+		t.Errorf("Expected 3 requests sampled from the quota, got %d", sampled)
+	}
+
+	// The rules were modified after they were fetched, so they are fetched
+	// again.
+	waitFor(t, func() bool { return fake.getRuleRequests() == 2 })
+
+	// The rule is not reported again until the interval of its target has
+	// elapsed.
+	for _, offset := range []time.Duration{time.Second, time.Minute} {
+		clock.Set(now.Add(offset))
+		if err := strategy.refreshTargets(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fake.Lock()
+	statistics = fake.statistics
+	fake.Unlock()
+
+	if len(statistics) != 2 {
+		t.Fatalf("Expected 2 statistics documents, got %d", len(statistics))
+	}
+
+	if statistics[1].RequestCount != 10 {
+		t.Errorf("Expected 10 requests reported, got %d",
+			statistics[1].RequestCount)
+	}
+}
+
+func TestCentralizedStrategyUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := server.URL
+	server.Close()
+
+	errs := make(chan error, 1)
+	strategy := NewCentralizedStrategy(CentralizedConfig{
+		Endpoint: endpoint,
+		Fallback: constantStrategy(true),
+		ErrorHandler: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	defer strategy.Close()
+
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an error getting sampling rules")
+	}
+
+	if !strategy.ShouldTrace(&Request{}) {
+		t.Error("Requests should be traced by the fallback")
+	}
+}
+
+func TestCentralizedStrategyInvalidDaemonAddress(t *testing.T) {
+	defer os.Unsetenv(utils.DaemonAddressEnv)
+	os.Setenv(utils.DaemonAddressEnv, "invalid")
+
+	errs := make(chan error, 10)
+	strategy := NewCentralizedStrategy(CentralizedConfig{
+		RulesInterval: time.Hour,
+		HTTPClient:    &http.Client{Timeout: time.Millisecond},
+		ErrorHandler: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	defer strategy.Close()
+
+	if strategy.config.Endpoint != "http://"+utils.DefaultDaemonAddress {
+		t.Errorf("Expected endpoint to fall back to %s, got %s",
+			utils.DefaultDaemonAddress, strategy.config.Endpoint)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "invalid daemon address") {
+			t.Errorf("Expected an invalid daemon address error, got '%s'",
+				err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an invalid daemon address error")
+	}
+}
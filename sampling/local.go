@@ -0,0 +1,162 @@
+package sampling
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/goguardian/aws-xray-go/utils"
+	"io/ioutil"
+)
+
+const localRulesVersion = 2
+
+// localRulesDocument represents a local sampling rules file.
+type localRulesDocument struct {
+	Version int          `json:"version"`
+	Rules   []*localRule `json:"rules"`
+	Default *localRule   `json:"default"`
+}
+
+// localRule represents a rule of a local sampling rules file.  Each rule
+// traces the first FixedTarget matching requests each second and Rate of the
+// other matching requests.
+type localRule struct {
+	Description string   `json:"description"`
+	Host        string   `json:"host"`
+	HTTPMethod  string   `json:"http_method"`
+	URLPath     string   `json:"url_path"`
+	FixedTarget *int     `json:"fixed_target"`
+	Rate        *float64 `json:"rate"`
+
+	sampler *utils.Sampler
+}
+
+// validate returns an error if the rate or fixed target of the rule are
+// missing or out of range, and creates the sampler of a valid rule.
+func (r *localRule) validate() error {
+	if r.FixedTarget == nil {
+		return errors.New("missing fixed_target")
+	}
+
+	if r.Rate == nil {
+		return errors.New("missing rate")
+	}
+
+	if *r.FixedTarget < 0 {
+		return fmt.Errorf("invalid fixed_target %d", *r.FixedTarget)
+	}
+
+	if *r.Rate < 0 || *r.Rate > 1 {
+		return fmt.Errorf("invalid rate %g", *r.Rate)
+	}
+
+	r.sampler = utils.NewSampler(uint32(*r.FixedTarget), *r.Rate)
+
+	return nil
+}
+
+// matches returns whether the request matches the host, method and path of
+// the rule.
+func (r *localRule) matches(request *Request) bool {
+	return wildcardMatch(r.Host, request.Host) &&
+		wildcardMatch(r.HTTPMethod, request.Method) &&
+		wildcardMatch(r.URLPath, request.URLPath)
+}
+
+// LocalStrategy traces requests according to rules in the X-Ray local
+// sampling rules format, version 2.  The first rule matching the host, HTTP
+// method and URL path of a request decides whether it is traced, and requests
+// matching no rule are decided by the default rule.
+type LocalStrategy struct {
+	rules       []*localRule
+	defaultRule *localRule
+}
+
+// NewLocalStrategy creates a new LocalStrategy from the JSON of a local
+// sampling rules document, for example:
+//
+//	{
+//	  "version": 2,
+//	  "rules": [
+//	    {
+//	      "description": "Health checks",
+//	      "host": "*",
+//	      "http_method": "GET",
+//	      "url_path": "/health",
+//	      "fixed_target": 0,
+//	      "rate": 0
+//	    }
+//	  ],
+//	  "default": {"fixed_target": 1, "rate": 0.05}
+//	}
+//
+// Host, method and path patterns may use "*" to match any number of
+// characters and "?" to match exactly one.
+func NewLocalStrategy(rules []byte) (*LocalStrategy, error) {
+	document := localRulesDocument{}
+	if err := json.Unmarshal(rules, &document); err != nil {
+		return nil, fmt.Errorf("error parsing sampling rules: %s", err.Error())
+	}
+
+	if document.Version != localRulesVersion {
+		return nil, fmt.Errorf("unsupported sampling rules version %d, "+
+			"expected %d", document.Version, localRulesVersion)
+	}
+
+	if document.Default == nil {
+		return nil, errors.New("missing default sampling rule")
+	}
+
+	if err := document.Default.validate(); err != nil {
+		return nil, fmt.Errorf("invalid default sampling rule: %s",
+			err.Error())
+	}
+
+	for i, rule := range document.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("invalid sampling rule %d: null", i)
+		}
+
+		if rule.Host == "" || rule.HTTPMethod == "" || rule.URLPath == "" {
+			return nil, fmt.Errorf("invalid sampling rule %d: host, "+
+				"http_method and url_path are required", i)
+		}
+
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid sampling rule %d: %s", i,
+				err.Error())
+		}
+	}
+
+	return &LocalStrategy{
+		rules:       document.Rules,
+		defaultRule: document.Default,
+	}, nil
+}
+
+// NewLocalStrategyFromFile creates a new LocalStrategy from a local sampling
+// rules file.
+func NewLocalStrategyFromFile(path string) (*LocalStrategy, error) {
+	rules, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sampling rules: %s", err.Error())
+	}
+
+	return NewLocalStrategy(rules)
+}
+
+// ShouldTrace returns whether the request is traced according to the first
+// matching rule, or the default rule.
+func (s *LocalStrategy) ShouldTrace(request *Request) bool {
+	if request == nil {
+		request = &Request{}
+	}
+
+	for _, rule := range s.rules {
+		if rule.matches(request) {
+			return rule.sampler.IsSampled()
+		}
+	}
+
+	return s.defaultRule.sampler.IsSampled()
+}
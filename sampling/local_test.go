@@ -0,0 +1,134 @@
+package sampling
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testLocalRules = `{
+  "version": 2,
+  "rules": [
+    {
+      "description": "Health checks",
+      "host": "*",
+      "http_method": "*",
+      "url_path": "/health",
+      "fixed_target": 0,
+      "rate": 0
+    },
+    {
+      "description": "Checkout",
+      "host": "*.example.com",
+      "http_method": "POST",
+      "url_path": "/checkout/*",
+      "fixed_target": 0,
+      "rate": 1
+    }
+  ],
+  "default": {"fixed_target": 0, "rate": 0}
+}`
+
+func TestLocalStrategy(t *testing.T) {
+	strategy, err := NewLocalStrategy([]byte(testLocalRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		request *Request
+		expect  bool
+	}{
+		{&Request{Host: "shop.example.com", Method: "GET",
+			URLPath: "/health"}, false},
+		{&Request{Host: "shop.example.com", Method: "POST",
+			URLPath: "/checkout/cart"}, true},
+		{&Request{Host: "shop.example.com", Method: "post",
+			URLPath: "/checkout/cart"}, true},
+		{&Request{Host: "shop.example.com", Method: "GET",
+			URLPath: "/checkout/cart"}, false},
+		{&Request{Host: "shop.example.org", Method: "POST",
+			URLPath: "/checkout/cart"}, false},
+		{&Request{}, false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 10; i++ {
+			if result := strategy.ShouldTrace(test.request); result != test.expect {
+				t.Errorf("Expected trace %t for '%+v', got %t", test.expect,
+					test.request, result)
+				break
+			}
+		}
+	}
+}
+
+func TestLocalStrategyDefault(t *testing.T) {
+	strategy, err := NewLocalStrategy([]byte(`{
+		"version": 2,
+		"default": {"fixed_target": 1, "rate": 0}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traced := 0
+	for i := 0; i < 10; i++ {
+		if strategy.ShouldTrace(&Request{URLPath: "/"}) {
+			traced++
+		}
+	}
+
+	if traced != 1 {
+		t.Errorf("Expected 1 traced request, got %d", traced)
+	}
+}
+
+func TestLocalStrategyInvalid(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`{"version": 1, "default": {"fixed_target": 1, "rate": 0.05}}`,
+		`{"version": 2}`,
+		`{"version": 2, "default": {"rate": 0.05}}`,
+		`{"version": 2, "default": {"fixed_target": 1}}`,
+		`{"version": 2, "default": {"fixed_target": -1, "rate": 0.05}}`,
+		`{"version": 2, "default": {"fixed_target": 1, "rate": 1.5}}`,
+		`{"version": 2, "default": {"fixed_target": 1, "rate": 0.05},
+		  "rules": [null]}`,
+		`{"version": 2, "default": {"fixed_target": 1, "rate": 0.05},
+		  "rules": [{"host": "*", "http_method": "*", "fixed_target": 1,
+		  "rate": 0.05}]}`,
+		`{"version": 2, "default": {"fixed_target": 1, "rate": 0.05},
+		  "rules": [{"host": "*", "http_method": "*", "url_path": "*",
+		  "rate": 0.05}]}`,
+	}
+
+	for _, test := range tests {
+		if _, err := NewLocalStrategy([]byte(test)); err == nil {
+			t.Errorf("Expected an error for '%s'", test)
+		}
+	}
+}
+
+func TestNewLocalStrategyFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sampling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	if err := ioutil.WriteFile(path, []byte(testLocalRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewLocalStrategyFromFile(path); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := NewLocalStrategyFromFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Missing rules file should error")
+	}
+}
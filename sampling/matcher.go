@@ -0,0 +1,45 @@
+package sampling
+
+import (
+	"strings"
+)
+
+// wildcardMatch returns whether the text matches the pattern, where "*"
+// matches any number of characters and "?" matches exactly one.  Matching is
+// case insensitive.
+func wildcardMatch(pattern string, text string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	pattern = strings.ToLower(pattern)
+	text = strings.ToLower(text)
+
+	p, t := 0, 0
+	star, match := -1, 0
+
+	for t < len(text) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == text[t]):
+			p++
+			t++
+		case p < len(pattern) && pattern[p] == '*':
+			star = p
+			match = t
+			p++
+		case star >= 0:
+			// Let the last star consume one more character.
+			p = star + 1
+			match++
+			t = match
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
@@ -0,0 +1,35 @@
+package sampling
+
+import "testing"
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		expect  bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"", "", true},
+		{"", "a", false},
+		{"GET", "get", true},
+		{"GET", "POST", false},
+		{"/api/*", "/api/users/1", true},
+		{"/api/*", "/health", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"/users/?", "/users/1", true},
+		{"/users/?", "/users/12", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"a**", "a", true},
+		{"?*", "", false},
+	}
+
+	for _, test := range tests {
+		if result := wildcardMatch(test.pattern, test.text); result != test.expect {
+			t.Errorf("Expected match of '%s' and '%s' to be %t, got %t",
+				test.pattern, test.text, test.expect, result)
+		}
+	}
+}
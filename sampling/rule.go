@@ -0,0 +1,156 @@
+package sampling
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// samplingRule represents a sampling rule from GetSamplingRules.
+type samplingRule struct {
+	RuleName      string            `json:"RuleName"`
+	Priority      int               `json:"Priority"`
+	FixedRate     float64           `json:"FixedRate"`
+	ReservoirSize int               `json:"ReservoirSize"`
+	ServiceName   string            `json:"ServiceName"`
+	ServiceType   string            `json:"ServiceType"`
+	Host          string            `json:"Host"`
+	HTTPMethod    string            `json:"HTTPMethod"`
+	URLPath       string            `json:"URLPath"`
+	ResourceARN   string            `json:"ResourceARN"`
+	Version       int               `json:"Version"`
+	Attributes    map[string]string `json:"Attributes"`
+}
+
+// supported returns whether the rule can be applied by the SDK.  Rules for
+// specific resources or with attributes cannot be matched.
+func (r *samplingRule) supported() bool {
+	return r.Version == 1 && r.ResourceARN == "*" && len(r.Attributes) == 0
+}
+
+// matches returns whether the request matches the rule.
+func (r *samplingRule) matches(request *Request) bool {
+	return wildcardMatch(r.ServiceName, request.ServiceName) &&
+		wildcardMatch(r.ServiceType, request.ServiceType) &&
+		wildcardMatch(r.Host, request.Host) &&
+		wildcardMatch(r.HTTPMethod, request.Method) &&
+		wildcardMatch(r.URLPath, request.URLPath)
+}
+
+// centralizedRule represents a sampling rule with its reservoir, the targets
+// assigned to it by GetSamplingTargets, and the statistics reported back.
+type centralizedRule struct {
+	samplingRule
+
+	quota          int
+	quotaTTL       time.Time
+	hasQuota       bool
+	currentSecond  int64
+	usedThisSecond int
+
+	requests int
+	sampled  int
+	borrowed int
+	reportAt time.Time
+
+	rand *rand.Rand
+
+	sync.Mutex
+}
+
+func newCentralizedRule(rule samplingRule) *centralizedRule {
+	return &centralizedRule{
+		samplingRule: rule,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// sample returns whether a request matching the rule is traced.  Requests
+// are traced while the reservoir quota for the current second lasts, then at
+// the fixed rate.  Without an unexpired quota, one request per second is
+// borrowed from the reservoir.
+func (r *centralizedRule) sample(now time.Time) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	r.requests++
+
+	if second := now.Unix(); second != r.currentSecond {
+		r.currentSecond = second
+		r.usedThisSecond = 0
+	}
+
+	if r.hasQuota && now.Before(r.quotaTTL) {
+		if r.usedThisSecond < r.quota {
+			r.usedThisSecond++
+			r.sampled++
+			return true
+		}
+	} else if r.ReservoirSize > 0 && r.usedThisSecond < 1 {
+		r.usedThisSecond++
+		r.sampled++
+		r.borrowed++
+		return true
+	}
+
+	if r.rand.Float64() < r.FixedRate {
+		r.sampled++
+		return true
+	}
+
+	return false
+}
+
+// update replaces the rule properties, keeping the reservoir and statistics.
+func (r *centralizedRule) update(rule samplingRule) {
+	r.Lock()
+	defer r.Unlock()
+	r.samplingRule = rule
+}
+
+// applyTarget updates the fixed rate and reservoir quota of the rule, and
+// schedules its next report after the interval of the target.
+func (r *centralizedRule) applyTarget(target samplingTarget, now time.Time) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.FixedRate = target.FixedRate
+
+	r.reportAt = time.Time{}
+	if target.Interval != nil && *target.Interval > 0 {
+		r.reportAt = now.Add(time.Duration(*target.Interval) * time.Second)
+	}
+
+	if target.ReservoirQuota != nil {
+		r.quota = *target.ReservoirQuota
+		r.hasQuota = true
+	}
+
+	if target.ReservoirQuotaTTL != nil {
+		r.quotaTTL = epochTime(*target.ReservoirQuotaTTL)
+	}
+}
+
+// due returns whether the statistics of the rule should be reported.
+func (r *centralizedRule) due(now time.Time) bool {
+	r.Lock()
+	defer r.Unlock()
+	return !now.Before(r.reportAt)
+}
+
+// statistics returns the statistics of the rule since they were last taken,
+// and resets them.
+func (r *centralizedRule) statistics() (requests, sampled, borrowed int) {
+	r.Lock()
+	defer r.Unlock()
+
+	requests, sampled, borrowed = r.requests, r.sampled, r.borrowed
+	r.requests, r.sampled, r.borrowed = 0, 0, 0
+
+	return
+}
+
+// epochTime converts seconds since the epoch to a time.
+func epochTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
@@ -0,0 +1,150 @@
+package sampling
+
+import (
+	"testing"
+	"time"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestSamplingRuleSupported(t *testing.T) {
+	tests := []struct {
+		rule   samplingRule
+		expect bool
+	}{
+		{samplingRule{Version: 1, ResourceARN: "*"}, true},
+		{samplingRule{Version: 2, ResourceARN: "*"}, false},
+		{samplingRule{Version: 1, ResourceARN: "arn:aws:ec2:*"}, false},
+		{samplingRule{Version: 1, ResourceARN: "*",
+			Attributes: map[string]string{"a": "b"}}, false},
+	}
+
+	for _, test := range tests {
+		if result := test.rule.supported(); result != test.expect {
+			t.Errorf("Expected supported %t for '%+v', got %t", test.expect,
+				test.rule, result)
+		}
+	}
+}
+
+func TestSamplingRuleMatches(t *testing.T) {
+	rule := samplingRule{
+		ServiceName: "api-*",
+		ServiceType: "*",
+		Host:        "*.example.com",
+		HTTPMethod:  "GET",
+		URLPath:     "/users/*",
+	}
+
+	tests := []struct {
+		request Request
+		expect  bool
+	}{
+		{Request{ServiceName: "api-users", Host: "www.example.com",
+			Method: "GET", URLPath: "/users/1"}, true},
+		{Request{ServiceName: "api-users", Host: "www.example.com",
+			Method: "POST", URLPath: "/users/1"}, false},
+		{Request{ServiceName: "web", Host: "www.example.com",
+			Method: "GET", URLPath: "/users/1"}, false},
+		{Request{ServiceName: "api-users", Host: "example.org",
+			Method: "GET", URLPath: "/users/1"}, false},
+		{Request{ServiceName: "api-users", Host: "www.example.com",
+			Method: "GET", URLPath: "/health"}, false},
+	}
+
+	for _, test := range tests {
+		if result := rule.matches(&test.request); result != test.expect {
+			t.Errorf("Expected match %t for '%+v', got %t", test.expect,
+				test.request, result)
+		}
+	}
+}
+
+func TestCentralizedRuleBorrow(t *testing.T) {
+	rule := newCentralizedRule(samplingRule{ReservoirSize: 10})
+	now := time.Unix(1000, 0)
+
+	if !rule.sample(now) {
+		t.Error("First request of the second should be borrowed")
+	}
+
+	if rule.sample(now) {
+		t.Error("Second request of the second should not be sampled")
+	}
+
+	if !rule.sample(now.Add(time.Second)) {
+		t.Error("First request of the next second should be borrowed")
+	}
+
+	requests, sampled, borrowed := rule.statistics()
+	if requests != 3 || sampled != 2 || borrowed != 2 {
+		t.Errorf("Expected statistics 3, 2, 2, got %d, %d, %d", requests,
+			sampled, borrowed)
+	}
+
+	requests, sampled, borrowed = rule.statistics()
+	if requests != 0 || sampled != 0 || borrowed != 0 {
+		t.Error("Statistics should be reset after they are taken")
+	}
+}
+
+func TestCentralizedRuleQuota(t *testing.T) {
+	rule := newCentralizedRule(samplingRule{ReservoirSize: 10})
+	now := time.Unix(1000, 0)
+
+	rule.applyTarget(samplingTarget{
+		FixedRate:         0,
+		ReservoirQuota:    intPtr(3),
+		ReservoirQuotaTTL: floatPtr(1010),
+	}, now)
+
+	sampled := 0
+	for i := 0; i < 10; i++ {
+		if rule.sample(now) {
+			sampled++
+		}
+	}
+
+	if sampled != 3 {
+		t.Errorf("Expected 3 requests sampled from the quota, got %d", sampled)
+	}
+
+	_, _, borrowed := rule.statistics()
+	if borrowed != 0 {
+		t.Errorf("Expected no borrowed requests, got %d", borrowed)
+	}
+
+	// Once the quota expires, requests are borrowed again.
+	expired := time.Unix(1011, 0)
+	if !rule.sample(expired) || rule.sample(expired) {
+		t.Error("Expected one request borrowed after the quota expires")
+	}
+}
+
+func TestCentralizedRuleFixedRate(t *testing.T) {
+	rule := newCentralizedRule(samplingRule{FixedRate: 1})
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 10; i++ {
+		if !rule.sample(now) {
+			t.Fatal("All requests should be sampled at a fixed rate of 1")
+		}
+	}
+
+	rule.applyTarget(samplingTarget{FixedRate: 0}, time.Now())
+	if rule.sample(now) {
+		t.Error("Requests should not be sampled at a fixed rate of 0")
+	}
+
+	rule.update(samplingRule{RuleName: "renamed", FixedRate: 1})
+	if _, sampled, _ := rule.statistics(); sampled != 10 {
+		t.Errorf("Statistics should be kept when the rule is updated, got %d",
+			sampled)
+	}
+}
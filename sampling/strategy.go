@@ -0,0 +1,60 @@
+// Package sampling decides which requests are traced.
+package sampling
+
+import (
+	"context"
+	"github.com/goguardian/aws-xray-go/utils"
+)
+
+// Request represents the properties of a request that sampling rules are
+// matched against.
+type Request struct {
+	Host        string
+	Method      string
+	URLPath     string
+	ServiceName string
+	ServiceType string
+}
+
+// Strategy represents a way of deciding whether requests are traced.
+type Strategy interface {
+	ShouldTrace(request *Request) bool
+}
+
+type contextKey int
+
+const requestContextKey contextKey = iota
+
+// NewContext returns a copy of the context carrying the request to be
+// sampled.
+func NewContext(ctx context.Context, request *Request) context.Context {
+	return context.WithValue(ctx, requestContextKey, request)
+}
+
+// FromContext returns the request to be sampled carried by the context, if
+// any.
+func FromContext(ctx context.Context) (*Request, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	request, ok := ctx.Value(requestContextKey).(*Request)
+	return request, ok && request != nil
+}
+
+// SamplerStrategy traces requests with a utils.Sampler, regardless of the
+// request properties.
+type SamplerStrategy struct {
+	Sampler *utils.Sampler
+}
+
+// NewSamplerStrategy creates a new strategy tracing the first fixedTarget
+// requests each second and fallbackRate of the other requests.
+func NewSamplerStrategy(fixedTarget uint32, fallbackRate float64) *SamplerStrategy {
+	return &SamplerStrategy{Sampler: utils.NewSampler(fixedTarget, fallbackRate)}
+}
+
+// ShouldTrace returns whether the sampler samples the request.
+func (s *SamplerStrategy) ShouldTrace(request *Request) bool {
+	return s.Sampler.IsSampled()
+}
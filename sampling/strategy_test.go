@@ -0,0 +1,37 @@
+package sampling
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(nil); ok {
+		t.Error("Nil context should not carry a request")
+	}
+
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("Empty context should not carry a request")
+	}
+
+	request := &Request{Host: "example.com"}
+	result, ok := FromContext(NewContext(context.Background(), request))
+	if !ok || result != request {
+		t.Errorf("Expected request '%+v', got '%+v'", request, result)
+	}
+}
+
+func TestSamplerStrategy(t *testing.T) {
+	strategy := NewSamplerStrategy(1, 0)
+
+	traced := 0
+	for i := 0; i < 10; i++ {
+		if strategy.ShouldTrace(&Request{}) {
+			traced++
+		}
+	}
+
+	if traced != 1 {
+		t.Errorf("Expected 1 traced request, got %d", traced)
+	}
+}
@@ -0,0 +1,383 @@
+package segment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/goguardian/aws-xray-go/utils"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	apiService              = "xray"
+	apiPath                 = "/TraceSegments"
+	defaultAPIBatchSize     = 50
+	defaultAPIQueueSize     = 1000
+	defaultAPIFlushInterval = 1 * time.Second
+	defaultAPIMaxRetries    = 3
+	apiRetryBaseDelay       = 100 * time.Millisecond
+)
+
+// APIEmitterConfig represents the configuration of an APIEmitter.  Zero values
+// are replaced with defaults.
+type APIEmitterConfig struct {
+	// Endpoint is the base URL of the X-Ray API.  It defaults to the regional
+	// X-Ray endpoint.
+	Endpoint string
+	// Region is used for the default endpoint and request signing.  It
+	// defaults to AWS_REGION, then AWS_DEFAULT_REGION.
+	Region string
+	// Credentials are used to sign requests when CredentialsProvider is not
+	// set.  Requests are not signed without an access key.
+	Credentials *utils.Credentials
+	// CredentialsProvider is called for each request to get the credentials
+	// used to sign it, so temporary credentials can be refreshed.  It
+	// defaults to Credentials, or the credentials in the environment read at
+	// each request.
+	CredentialsProvider func() (utils.Credentials, error)
+	// BatchSize is the maximum number of documents in a request.
+	BatchSize int
+	// QueueSize is the maximum number of documents waiting to be sent.
+	QueueSize int
+	// FlushInterval is how often waiting documents are sent.
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed request or unprocessed
+	// document is retried.
+	MaxRetries int
+	// HTTPClient is the client used to send requests.
+	HTTPClient *http.Client
+	// ErrorHandler, if set, is called with errors from background flushes.
+	ErrorHandler func(err error)
+}
+
+// APIEmitter sends documents directly to the X-Ray PutTraceSegments API
+// instead of the daemon.  Documents are batched and sent in the background.
+type APIEmitter struct {
+	config  APIEmitterConfig
+	queue   []apiDocument
+	flushes chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	closed  bool
+
+	flushMutex sync.Mutex
+	sync.Mutex
+}
+
+// apiDocument represents an encoded document and its ID, which is used to
+// match unprocessed documents in API responses.
+type apiDocument struct {
+	id   string
+	body []byte
+}
+
+type putTraceSegmentsInput struct {
+	TraceSegmentDocuments []string `json:"TraceSegmentDocuments"`
+}
+
+type putTraceSegmentsOutput struct {
+	UnprocessedTraceSegments []struct {
+		ID        string `json:"Id"`
+		ErrorCode string `json:"ErrorCode"`
+		Message   string `json:"Message"`
+	} `json:"UnprocessedTraceSegments"`
+}
+
+// NewAPIEmitter creates a new APIEmitter and starts its background flushing.
+func NewAPIEmitter(config APIEmitterConfig) *APIEmitter {
+	if config.Region == "" {
+		config.Region = utils.GetenvOrDefault("AWS_REGION",
+			utils.GetenvOrDefault("AWS_DEFAULT_REGION", "us-east-1"))
+	}
+
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://xray.%s.amazonaws.com",
+			config.Region)
+	}
+
+	if config.CredentialsProvider == nil {
+		if credentials := config.Credentials; credentials != nil {
+			config.CredentialsProvider = func() (utils.Credentials, error) {
+				return *credentials, nil
+			}
+		} else {
+			config.CredentialsProvider = func() (utils.Credentials, error) {
+				return utils.CredentialsFromEnv(), nil
+			}
+		}
+	}
+
+	if config.BatchSize <= 0 || config.BatchSize > defaultAPIBatchSize {
+		config.BatchSize = defaultAPIBatchSize
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultAPIQueueSize
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultAPIFlushInterval
+	}
+
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultAPIMaxRetries
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	e := &APIEmitter{
+		config:  config,
+		flushes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go e.run()
+
+	return e
+}
+
+func (e *APIEmitter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flushes:
+		case <-e.done:
+			return
+		}
+
+		if err := e.Flush(context.Background()); err != nil &&
+			e.config.ErrorHandler != nil {
+			e.config.ErrorHandler(err)
+		}
+	}
+}
+
+// Send encodes the document and queues it to be sent with the next batch.
+func (e *APIEmitter) Send(doc Document) error {
+	body, err := encode(doc, maxBodySize)
+	if err != nil {
+		return err
+	}
+
+	header := struct {
+		ID string `json:"id"`
+	}{}
+	json.Unmarshal(body, &header)
+
+	e.Lock()
+	defer e.Unlock()
+
+	if e.closed {
+		return ErrEmitterClosed
+	}
+
+	if len(e.queue) >= e.config.QueueSize {
+		recordDroppedQueueFull()
+		return ErrQueueFull
+	}
+
+	e.queue = append(e.queue, apiDocument{id: header.ID, body: body})
+
+	if len(e.queue) >= e.config.BatchSize {
+		select {
+		case e.flushes <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Flush sends all queued documents, returning the first error encountered.
+func (e *APIEmitter) Flush(ctx context.Context) error {
+	e.flushMutex.Lock()
+	defer e.flushMutex.Unlock()
+
+	e.Lock()
+	queue := e.queue
+	e.queue = nil
+	e.Unlock()
+
+	var err error
+	for len(queue) > 0 {
+		size := e.config.BatchSize
+		if size > len(queue) {
+			size = len(queue)
+		}
+
+		if batchErr := e.putTraceSegments(ctx, queue[:size]); batchErr != nil &&
+			err == nil {
+			err = batchErr
+		}
+
+		queue = queue[size:]
+	}
+
+	return err
+}
+
+// Close stops the background flushing and sends all queued documents.
+func (e *APIEmitter) Close(ctx context.Context) error {
+	e.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.done)
+	}
+	e.Unlock()
+
+	<-e.stopped
+
+	return e.Flush(ctx)
+}
+
+// putTraceSegments sends a batch of documents, retrying failed requests and
+// unprocessed documents with exponential backoff.
+func (e *APIEmitter) putTraceSegments(
+	ctx context.Context,
+	batch []apiDocument,
+) error {
+
+	var err error
+	for attempt := 0; attempt <= e.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(apiRetryBaseDelay << uint(attempt-1)):
+			case <-ctx.Done():
+				recordWriteErrors(len(batch))
+				return ctx.Err()
+			}
+		}
+
+		var unprocessed []apiDocument
+		var retry bool
+		unprocessed, retry, err = e.put(ctx, batch)
+		if err == nil {
+			recordBatchSent(batch, nil)
+			return nil
+		}
+
+		if unprocessed != nil {
+			recordBatchSent(batch, unprocessed)
+			batch = unprocessed
+		}
+
+		if !retry {
+			break
+		}
+	}
+
+	recordWriteErrors(len(batch))
+
+	return err
+}
+
+// recordBatchSent records the documents of a batch that are not unprocessed
+// as sent.
+func recordBatchSent(batch []apiDocument, unprocessed []apiDocument) {
+	skip := map[string]bool{}
+	for _, doc := range unprocessed {
+		skip[doc.id] = true
+	}
+
+	documents, bytes := 0, 0
+	for _, doc := range batch {
+		if !skip[doc.id] {
+			documents++
+			bytes += len(doc.body)
+		}
+	}
+
+	recordSent(documents, bytes)
+}
+
+// put performs a single PutTraceSegments request.  It returns the documents
+// that were not processed and whether the failure may be retried.
+func (e *APIEmitter) put(
+	ctx context.Context,
+	batch []apiDocument,
+) ([]apiDocument, bool, error) {
+
+	input := putTraceSegmentsInput{}
+	for _, doc := range batch {
+		input.TraceSegmentDocuments = append(input.TraceSegmentDocuments,
+			string(doc.body))
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.config.Endpoint+apiPath,
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	credentials, err := e.config.CredentialsProvider()
+	if err != nil {
+		return nil, true, fmt.Errorf("error getting credentials: %s",
+			err.Error())
+	}
+
+	if credentials.AccessKeyID != "" {
+		utils.SignV4(req, body, credentials, e.config.Region, apiService,
+			time.Now())
+	}
+
+	res, err := e.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("error sending trace segments: %s",
+			err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		retry := res.StatusCode == http.StatusTooManyRequests ||
+			res.StatusCode >= 500
+		return nil, retry, fmt.Errorf("error sending trace segments: "+
+			"status code %d", res.StatusCode)
+	}
+
+	output := putTraceSegmentsOutput{}
+	if err := json.NewDecoder(res.Body).Decode(&output); err != nil {
+		return nil, false, fmt.Errorf("error decoding trace segments "+
+			"response: %s", err.Error())
+	}
+
+	if len(output.UnprocessedTraceSegments) == 0 {
+		return nil, false, nil
+	}
+
+	unprocessedIDs := map[string]bool{}
+	for _, unprocessed := range output.UnprocessedTraceSegments {
+		unprocessedIDs[unprocessed.ID] = true
+	}
+
+	unprocessed := []apiDocument{}
+	for _, doc := range batch {
+		if unprocessedIDs[doc.id] {
+			unprocessed = append(unprocessed, doc)
+		}
+	}
+
+	first := output.UnprocessedTraceSegments[0]
+	return unprocessed, len(unprocessed) > 0, fmt.Errorf("%d unprocessed "+
+		"trace segments: %s: %s", len(output.UnprocessedTraceSegments),
+		first.ErrorCode, first.Message)
+}
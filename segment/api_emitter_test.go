@@ -0,0 +1,217 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/goguardian/aws-xray-go/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// apiStandIn is a stand-in for the PutTraceSegments API that records received
+// documents and can reject requests or documents.
+type apiStandIn struct {
+	failures        int
+	unprocessedID   string
+	documents       []string
+	authorization   string
+	requests        int
+	unprocessedSent bool
+
+	sync.Mutex
+}
+
+func (a *apiStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.requests++
+	a.authorization = r.Header.Get("Authorization")
+
+	if r.Method != http.MethodPost || r.URL.Path != "/TraceSegments" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if a.failures > 0 {
+		a.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	input := putTraceSegmentsInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	output := map[string]interface{}{"UnprocessedTraceSegments": []interface{}{}}
+	for _, doc := range input.TraceSegmentDocuments {
+		if !a.unprocessedSent && a.unprocessedID != "" &&
+			strings.Contains(doc, a.unprocessedID) {
+			a.unprocessedSent = true
+			output["UnprocessedTraceSegments"] = []interface{}{
+				map[string]string{
+					"Id":        a.unprocessedID,
+					"ErrorCode": "ThrottledException",
+					"Message":   "Rate exceeded",
+				},
+			}
+			continue
+		}
+
+		a.documents = append(a.documents, doc)
+	}
+
+	json.NewEncoder(w).Encode(output)
+}
+
+func TestAPIEmitter(t *testing.T) {
+	seg := New("segment", nil)
+	standIn := &apiStandIn{failures: 1, unprocessedID: seg.ID}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	emitter := NewAPIEmitter(APIEmitterConfig{
+		Endpoint:      server.URL,
+		Region:        "us-west-2",
+		Credentials:   &utils.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+		FlushInterval: time.Hour,
+	})
+
+	if err := emitter.Send(seg); err != nil {
+		t.Error(err)
+	}
+
+	for i := 0; i < 60; i++ {
+		if err := emitter.Send(New("segment", nil)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := emitter.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	standIn.Lock()
+	defer standIn.Unlock()
+
+	if len(standIn.documents) != 61 {
+		t.Errorf("Stand-in should receive 61 documents, got %d",
+			len(standIn.documents))
+	}
+
+	if !strings.Contains(standIn.authorization, "us-west-2/xray/aws4_request") {
+		t.Errorf("Requests should be signed, got authorization '%s'",
+			standIn.authorization)
+	}
+
+	if err := emitter.Send(seg); err != ErrEmitterClosed {
+		t.Errorf("Closed emitter should return ErrEmitterClosed, got %v", err)
+	}
+}
+
+func TestAPIEmitterRetriesExhausted(t *testing.T) {
+	standIn := &apiStandIn{failures: 10}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	emitter := NewAPIEmitter(APIEmitterConfig{
+		Endpoint:      server.URL,
+		Credentials:   &utils.Credentials{},
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+	})
+
+	emitter.Send(New("segment", nil))
+
+	if err := emitter.Flush(context.Background()); err == nil {
+		t.Error("Flush should error when retries are exhausted")
+	}
+
+	standIn.Lock()
+	defer standIn.Unlock()
+
+	if standIn.requests != 2 {
+		t.Errorf("Stand-in should receive 2 requests, got %d", standIn.requests)
+	}
+
+	if standIn.authorization != "" {
+		t.Error("Requests should not be signed without credentials")
+	}
+}
+
+func TestAPIEmitterQueueFull(t *testing.T) {
+	emitter := NewAPIEmitter(APIEmitterConfig{
+		Endpoint:      "http://127.0.0.1:1",
+		Credentials:   &utils.Credentials{},
+		BatchSize:     50,
+		QueueSize:     1,
+		FlushInterval: time.Hour,
+	})
+
+	if err := emitter.Send(New("segment", nil)); err != nil {
+		t.Error(err)
+	}
+
+	if err := emitter.Send(New("segment", nil)); err != ErrQueueFull {
+		t.Errorf("Send should return ErrQueueFull, got %v", err)
+	}
+}
+
+func TestAPIEmitterCredentialsProvider(t *testing.T) {
+	standIn := &apiStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	calls := 0
+	emitter := NewAPIEmitter(APIEmitterConfig{
+		Endpoint: server.URL,
+		Region:   "us-west-2",
+		CredentialsProvider: func() (utils.Credentials, error) {
+			calls++
+			if calls > 2 {
+				return utils.Credentials{}, errors.New("credentials expired")
+			}
+
+			return utils.Credentials{
+				AccessKeyID:     fmt.Sprintf("id%d", calls),
+				SecretAccessKey: "secret",
+				SessionToken:    "token",
+			}, nil
+		},
+		FlushInterval: time.Hour,
+		MaxRetries:    -1,
+	})
+
+	for i := 1; i <= 2; i++ {
+		emitter.Send(New("segment", nil))
+		if err := emitter.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		standIn.Lock()
+		authorization := standIn.authorization
+		standIn.Unlock()
+
+		expected := fmt.Sprintf("Credential=id%d/", i)
+		if !strings.Contains(authorization, expected) {
+			t.Errorf("Expected authorization with '%s', got '%s'", expected,
+				authorization)
+		}
+	}
+
+	emitter.Send(New("segment", nil))
+	if err := emitter.Flush(context.Background()); err == nil {
+		t.Error("Flush should error when credentials cannot be provided")
+	}
+}
@@ -0,0 +1,219 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const (
+	defaultQueueSize = 1000
+	defaultWorkers   = 1
+)
+
+// DropPolicy determines how an AsyncEmitter behaves when its queue is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the document being sent.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest queued document to make room.
+	DropOldest
+	// Block waits until there is room in the queue.
+	Block
+)
+
+var (
+	// ErrQueueFull is returned when a document is discarded because the queue
+	// of an AsyncEmitter is full.
+	ErrQueueFull = errors.New("emitter queue full")
+	// ErrEmitterClosed is returned when sending to a closed AsyncEmitter.
+	ErrEmitterClosed = errors.New("emitter closed")
+)
+
+// AsyncEmitterConfig represents the configuration of an AsyncEmitter.  Zero
+// values are replaced with defaults.
+type AsyncEmitterConfig struct {
+	// QueueSize is the maximum number of documents waiting to be sent.
+	QueueSize int
+	// Workers is the number of goroutines sending documents.
+	Workers int
+	// DropPolicy determines what happens when the queue is full.
+	DropPolicy DropPolicy
+	// ErrorHandler, if set, is called with errors returned by the wrapped
+	// emitter.
+	ErrorHandler func(err error)
+}
+
+// AsyncEmitter wraps an emitter so that documents are encoded and sent by a
+// pool of background workers instead of the goroutine closing the segment.
+type AsyncEmitter struct {
+	emitter      Emitter
+	queue        chan Document
+	dropPolicy   DropPolicy
+	errorHandler func(err error)
+	closed       bool
+	pending      int
+	drained      chan struct{}
+	pendingMutex sync.Mutex
+	stopped      chan struct{}
+	workers      sync.WaitGroup
+
+	sync.RWMutex
+}
+
+// NewAsyncEmitter creates a new AsyncEmitter sending documents with e and
+// starts its workers.
+func NewAsyncEmitter(e Emitter, config AsyncEmitterConfig) *AsyncEmitter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = defaultWorkers
+	}
+
+	a := &AsyncEmitter{
+		emitter:      e,
+		queue:        make(chan Document, config.QueueSize),
+		dropPolicy:   config.DropPolicy,
+		errorHandler: config.ErrorHandler,
+		drained:      make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	close(a.drained)
+
+	a.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go a.work()
+	}
+
+	go func() {
+		a.workers.Wait()
+		close(a.stopped)
+	}()
+
+	return a
+}
+
+func (a *AsyncEmitter) work() {
+	defer a.workers.Done()
+
+	for doc := range a.queue {
+		if err := a.emitter.Send(doc); err != nil && a.errorHandler != nil {
+			a.errorHandler(err)
+		}
+
+		a.done()
+	}
+}
+
+// add increments the number of documents that have not been sent, replacing
+// the drained channel when the queue stops being empty.
+func (a *AsyncEmitter) add() {
+	a.pendingMutex.Lock()
+	defer a.pendingMutex.Unlock()
+
+	if a.pending == 0 {
+		a.drained = make(chan struct{})
+	}
+	a.pending++
+}
+
+// done decrements the number of documents that have not been sent, closing
+// the drained channel when all of them have been.
+func (a *AsyncEmitter) done() {
+	a.pendingMutex.Lock()
+	defer a.pendingMutex.Unlock()
+
+	a.pending--
+	if a.pending == 0 {
+		close(a.drained)
+	}
+}
+
+// getDrained returns a channel closed once all documents added so far have
+// been sent.
+func (a *AsyncEmitter) getDrained() chan struct{} {
+	a.pendingMutex.Lock()
+	defer a.pendingMutex.Unlock()
+	return a.drained
+}
+
+// Send queues the document to be sent by a worker.  ErrQueueFull is returned
+// when the document is discarded because of the drop policy.
+func (a *AsyncEmitter) Send(doc Document) error {
+	a.RLock()
+	defer a.RUnlock()
+
+	if a.closed {
+		return ErrEmitterClosed
+	}
+
+	a.add()
+
+	if a.dropPolicy == Block {
+		a.queue <- doc
+		return nil
+	}
+
+	for {
+		select {
+		case a.queue <- doc:
+			return nil
+		default:
+		}
+
+		if a.dropPolicy != DropOldest {
+			a.done()
+			recordDroppedQueueFull()
+			return ErrQueueFull
+		}
+
+		select {
+		case <-a.queue:
+			a.done()
+			recordDroppedQueueFull()
+		default:
+		}
+	}
+}
+
+// Flush waits until all queued documents have been sent or the context is
+// done, then flushes the wrapped emitter if it holds documents to be sent
+// later.
+func (a *AsyncEmitter) Flush(ctx context.Context) error {
+	if err := wait(ctx, a.getDrained()); err != nil {
+		return err
+	}
+
+	if flusher, ok := a.emitter.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+
+	return nil
+}
+
+// Close stops accepting documents and waits for the queued documents to be
+// sent or the context to be done.
+func (a *AsyncEmitter) Close(ctx context.Context) error {
+	a.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.Unlock()
+
+	return wait(ctx, a.stopped)
+}
+
+// wait waits for done to be closed, or returns the context error if the
+// context is done first.
+func wait(ctx context.Context, done chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,178 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/goguardian/aws-xray-go/utils"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// blockingEmitter is an emitter that waits for a signal before recording
+// each document.
+type blockingEmitter struct {
+	release chan struct{}
+	memory  *MemoryEmitter
+}
+
+func (b *blockingEmitter) Send(doc Document) error {
+	<-b.release
+	return b.memory.Send(doc)
+}
+
+func TestAsyncEmitter(t *testing.T) {
+	memory := NewMemoryEmitter()
+	emitter := NewAsyncEmitter(memory, AsyncEmitterConfig{Workers: 2})
+
+	for i := 0; i < 10; i++ {
+		if err := emitter.Send(New("segment", nil)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := emitter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(memory.Documents()) != 10 {
+		t.Errorf("Memory emitter should have 10 documents, got %d",
+			len(memory.Documents()))
+	}
+
+	if err := emitter.Close(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if err := emitter.Send(New("segment", nil)); err != ErrEmitterClosed {
+		t.Errorf("Closed emitter should return ErrEmitterClosed, got %v", err)
+	}
+}
+
+func TestAsyncEmitterDropPolicy(t *testing.T) {
+	tests := []struct {
+		dropPolicy       DropPolicy
+		expectErr        error
+		expectDocuments  int
+		expectLastSentID int
+	}{
+		{dropPolicy: DropNewest, expectErr: ErrQueueFull, expectDocuments: 2, expectLastSentID: 1},
+		{dropPolicy: DropOldest, expectErr: nil, expectDocuments: 2, expectLastSentID: 2},
+	}
+
+	for _, test := range tests {
+		blocking := &blockingEmitter{
+			release: make(chan struct{}),
+			memory:  NewMemoryEmitter(),
+		}
+		emitter := NewAsyncEmitter(blocking, AsyncEmitterConfig{
+			QueueSize:  1,
+			DropPolicy: test.dropPolicy,
+		})
+
+		segments := []*Segment{New("0", nil), New("1", nil), New("2", nil)}
+
+		// The worker takes the first segment and blocks, the second fills the
+		// queue.
+		emitter.Send(segments[0])
+		for len(emitter.queue) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		emitter.Send(segments[1])
+
+		if err := emitter.Send(segments[2]); err != test.expectErr {
+			t.Errorf("Send should return %v, got %v", test.expectErr, err)
+		}
+
+		close(blocking.release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := emitter.Close(ctx); err != nil {
+			t.Error(err)
+		}
+		cancel()
+
+		documents := blocking.memory.Documents()
+		if len(documents) != test.expectDocuments {
+			t.Errorf("Expected %d documents, got %d",
+				test.expectDocuments, len(documents))
+			continue
+		}
+
+		last := &Segment{}
+		if err := json.Unmarshal(documents[len(documents)-1], last); err != nil {
+			t.Error(err)
+		}
+
+		if last.Name != segments[test.expectLastSentID].Name {
+			t.Errorf("Last document should be '%s', got '%s'",
+				segments[test.expectLastSentID].Name, last.Name)
+		}
+	}
+}
+
+func TestAsyncEmitterFlushTimeout(t *testing.T) {
+	blocking := &blockingEmitter{
+		release: make(chan struct{}),
+		memory:  NewMemoryEmitter(),
+	}
+	emitter := NewAsyncEmitter(blocking, AsyncEmitterConfig{})
+	defer close(blocking.release)
+
+	emitter.Send(New("segment", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := emitter.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Flush should return %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	// Flushes that time out should not leave goroutines waiting behind them.
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		emitter.Flush(ctx)
+		emitter.Close(ctx)
+	}
+
+	if leaked := runtime.NumGoroutine() - goroutines; leaked > 0 {
+		t.Errorf("Expected no goroutines left waiting, got %d", leaked)
+	}
+}
+
+func TestAsyncEmitterFlushAPIEmitter(t *testing.T) {
+	standIn := &apiStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	api := NewAPIEmitter(APIEmitterConfig{
+		Endpoint:      server.URL,
+		Region:        "us-west-2",
+		Credentials:   &utils.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+		FlushInterval: time.Hour,
+	})
+	emitter := NewAsyncEmitter(api, AsyncEmitterConfig{})
+
+	for i := 0; i < 3; i++ {
+		emitter.Send(New("segment", nil))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := emitter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	standIn.Lock()
+	defer standIn.Unlock()
+
+	if len(standIn.documents) != 3 {
+		t.Errorf("Flush should send the 3 batched documents, got %d",
+			len(standIn.documents))
+	}
+}
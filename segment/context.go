@@ -0,0 +1,64 @@
+package segment
+
+import (
+	"context"
+)
+
+type contextKey int
+
+const (
+	segmentContextKey contextKey = iota
+	subsegmentContextKey
+)
+
+// NewContext returns a copy of the context carrying the segment.
+func NewContext(ctx context.Context, seg *Segment) context.Context {
+	return context.WithValue(ctx, segmentContextKey, seg)
+}
+
+// FromContext returns the segment carried by the context, if any.
+func FromContext(ctx context.Context) (*Segment, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	seg, ok := ctx.Value(segmentContextKey).(*Segment)
+	return seg, ok && seg != nil
+}
+
+// NewSubsegmentContext returns a copy of the context carrying the subsegment
+// as the current subsegment.
+func NewSubsegmentContext(ctx context.Context, subseg *Subsegment) context.Context {
+	return context.WithValue(ctx, subsegmentContextKey, subseg)
+}
+
+// SubsegmentFromContext returns the current subsegment carried by the context,
+// if any.
+func SubsegmentFromContext(ctx context.Context) (*Subsegment, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	subseg, ok := ctx.Value(subsegmentContextKey).(*Subsegment)
+	return subseg, ok && subseg != nil
+}
+
+// AddNewNestedSubsegment adds a new subsegment to the current subsegment of the
+// context when it belongs to the segment, or to the segment otherwise.
+func (s *Segment) AddNewNestedSubsegment(
+	ctx context.Context,
+	name string,
+) *Subsegment {
+
+	if parent, ok := SubsegmentFromContext(ctx); ok {
+		parent.RLock()
+		owner := parent.Segment
+		parent.RUnlock()
+
+		if owner == s {
+			return parent.AddNewSubsegment(name)
+		}
+	}
+
+	return s.AddNewSubsegment(name)
+}
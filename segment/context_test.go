@@ -0,0 +1,85 @@
+package segment
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(nil); ok {
+		t.Error("A nil context should not have a segment")
+	}
+
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("A context with no segment should not have a segment")
+	}
+
+	seg := New("segment", nil)
+	ctx := NewContext(context.Background(), seg)
+
+	if result, ok := FromContext(ctx); !ok || result != seg {
+		t.Error("Segment should be retrieved from the context")
+	}
+
+	if _, ok := SubsegmentFromContext(ctx); ok {
+		t.Error("A context with no subsegment should not have a subsegment")
+	}
+
+	subseg := seg.AddNewSubsegment("subsegment")
+	ctx = NewSubsegmentContext(ctx, subseg)
+
+	if result, ok := SubsegmentFromContext(ctx); !ok || result != subseg {
+		t.Error("Subsegment should be retrieved from the context")
+	}
+
+	if result, ok := FromContext(ctx); !ok || result != seg {
+		t.Error("Segment should be retrieved from a subsegment context")
+	}
+}
+
+func TestNewInheritsContextSegment(t *testing.T) {
+	parent := New("parent", nil)
+	parent.ParentID = "53995c3f42cd8ad8"
+	parent.Traced = true
+
+	seg := New("segment", NewContext(context.Background(), parent))
+
+	if seg.TraceID != parent.TraceID {
+		t.Errorf("Expected trace ID '%s', got '%s'", parent.TraceID, seg.TraceID)
+	}
+
+	if seg.ParentID != parent.ParentID {
+		t.Errorf("Expected parent ID '%s', got '%s'", parent.ParentID,
+			seg.ParentID)
+	}
+
+	if !seg.Traced {
+		t.Error("Segment should be traced when the context segment is traced")
+	}
+}
+
+func TestAddNewNestedSubsegment(t *testing.T) {
+	seg := New("segment", nil)
+	ctx := NewContext(context.Background(), seg)
+
+	top := seg.AddNewNestedSubsegment(ctx, "top")
+	nested := seg.AddNewNestedSubsegment(NewSubsegmentContext(ctx, top), "nested")
+
+	other := New("other", nil).AddNewSubsegment("other")
+	foreign := seg.AddNewNestedSubsegment(NewSubsegmentContext(ctx, other),
+		"foreign")
+
+	if len(seg.Subsegments) != 2 || seg.Subsegments[0] != top ||
+		seg.Subsegments[1] != foreign {
+		t.Error("Subsegments without a context subsegment of the segment " +
+			"should be added to the segment")
+	}
+
+	if len(top.Subsegments) != 1 || top.Subsegments[0] != nested {
+		t.Error("Subsegment should be nested under the context subsegment")
+	}
+
+	if seg.Counter != 3 {
+		t.Errorf("Expected 3 open subsegments, got %d", seg.Counter)
+	}
+}
@@ -7,54 +7,233 @@ import (
 	"github.com/goguardian/aws-xray-go/utils"
 	"net"
 	"sync"
+	"time"
 )
 
 const (
-	maxBodySize = 64 * 1024 // 64KB
+	maxBodySize            = 64 * 1024 // 64KB
+	defaultResolveInterval = 1 * time.Minute
+	minReconnectBackoff    = 100 * time.Millisecond
+	maxReconnectBackoff    = 30 * time.Second
 )
 
 var (
 	protocolHeader    = []byte(`{"format": "json", "version": 1}`)
 	protocolDelimiter = []byte("\n")
-	daemonHost        = utils.GetenvOrDefault("XRAY_DAEMON_HOST", "127.0.0.1")
-	daemonPort        = utils.GetenvOrDefault("XRAY_DAEMON_PORT", "2000")
 )
 
-// Emitter represents an emitter.  The purpose of this struct is to provide a
-// cleaner interface within the "segment" package, allowing for an Emitter
-// instance (e.g. "emitter") to execute Send with "emitter.Send(seg)"
-type Emitter struct {
-	daemonAddress string
-	udpConn       net.Conn
+// Document represents a trace document that can be delivered by an Emitter.
+type Document interface {
+	Bytes() ([]byte, error)
+}
+
+// Emitter represents a destination for flushed trace documents.  The default
+// emitter sends documents to the X-Ray daemon over UDP, but any
+// implementation may be installed with SetEmitter.
+type Emitter interface {
+	Send(doc Document) error
+}
+
+// SetEmitter updates the emitter used when flushing segments.
+func SetEmitter(e Emitter) {
+	emitterMutex.Lock()
+	defer emitterMutex.Unlock()
+	emitter = e
+}
+
+// GetEmitter returns the emitter used when flushing segments.
+func GetEmitter() Emitter {
+	emitterMutex.RLock()
+	defer emitterMutex.RUnlock()
+	return emitter
+}
+
+// encode returns the JSON body of a document, enforcing the limit on the
+// encoded size when limit is greater than zero.  Documents over the limit are
+// trimmed according to the trim strategy.
+func encode(doc Document, limit int) ([]byte, error) {
+	body, err := doc.Bytes()
+	if err != nil {
+		recordEncodeError()
+		return nil, fmt.Errorf("error encoding segment: %s", err.Error())
+	}
+
+	if limit > 0 && len(body) > limit {
+		trimmed, ok := trim(body, limit)
+		if !ok {
+			recordDroppedOversize()
+			return nil, errors.New("segment too large. >64KB")
+		}
+
+		recordTrimmed()
+		body = trimmed
+	}
+
+	return body, nil
+}
+
+// UDPEmitter sends trace documents to the X-Ray daemon over UDP, or a Unix
+// datagram socket for "unixgram://path" addresses, using the daemon protocol
+// framing.  The connection is redialed periodically so changes
+// to the IP address of the daemon host are picked up, and after write errors,
+// with exponential backoff while the daemon is unreachable.
+type UDPEmitter struct {
+	daemonAddress   string
+	udpConn         net.Conn
+	dialedAt        time.Time
+	resolveInterval time.Duration
+	backoff         time.Duration
+	retryAt         time.Time
+	now             func() time.Time
 
 	sync.RWMutex
 }
 
-// NewEmitter creates a new emitter with default daemon address settings.
-func NewEmitter() *Emitter {
-	return &Emitter{daemonAddress: fmt.Sprintf("%s:%s", daemonHost, daemonPort)}
+// NewUDPEmitter creates a new UDP emitter with the daemon address configured
+// in the environment, falling back to the default address when the
+// environment is not valid.  Use NewUDPEmitterFromEnv to be told about an
+// invalid address.
+func NewUDPEmitter() *UDPEmitter {
+	emitter, err := NewUDPEmitterFromEnv()
+	if err != nil {
+		return newUDPEmitter(utils.DefaultDaemonAddress)
+	}
+
+	return emitter
 }
 
-func (e *Emitter) getConnection() (net.Conn, error) {
+// NewUDPEmitterFromEnv creates a new UDP emitter with the daemon address
+// configured in the environment, returning an error if the address is not
+// valid.
+func NewUDPEmitterFromEnv() (*UDPEmitter, error) {
+	daemonAddress, err := utils.GetDaemonAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	return newUDPEmitter(daemonAddress.UDP), nil
+}
+
+func newUDPEmitter(address string) *UDPEmitter {
+	return &UDPEmitter{
+		daemonAddress:   address,
+		resolveInterval: defaultResolveInterval,
+		now:             time.Now,
+	}
+}
+
+func (e *UDPEmitter) getConnection() (net.Conn, error) {
 	e.Lock()
 	defer e.Unlock()
 
-	if e.udpConn != nil {
+	now := e.now()
+
+	if e.udpConn != nil && (e.resolveInterval <= 0 ||
+		now.Sub(e.dialedAt) < e.resolveInterval) {
 		return e.udpConn, nil
 	}
 
-	var err error
-	e.udpConn, err = net.Dial("udp", e.daemonAddress)
+	if now.Before(e.retryAt) {
+		return nil, fmt.Errorf("daemon unreachable, retrying in %s",
+			e.retryAt.Sub(now))
+	}
+
+	if e.udpConn != nil {
+		e.udpConn.Close()
+		e.udpConn = nil
+	}
+
+	network, address := utils.DaemonNetwork(e.daemonAddress)
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		e.increaseBackoff(now)
+		return nil, err
+	}
+
+	e.udpConn = conn
+	e.dialedAt = now
+
+	return conn, nil
+}
+
+// connectionFailed closes the connection after a write error and delays
+// redialing.
+func (e *UDPEmitter) connectionFailed(conn net.Conn) {
+	e.Lock()
+	defer e.Unlock()
 
-	return e.udpConn, err
+	if e.udpConn != conn {
+		return
+	}
+
+	e.udpConn.Close()
+	e.udpConn = nil
+	e.increaseBackoff(e.now())
+}
+
+// connectionSucceeded resets the backoff after a successful write.
+func (e *UDPEmitter) connectionSucceeded() {
+	e.RLock()
+	backoff := e.backoff
+	e.RUnlock()
+
+	if backoff == 0 {
+		return
+	}
+
+	e.Lock()
+	e.backoff = 0
+	e.retryAt = time.Time{}
+	e.Unlock()
+}
+
+// increaseBackoff doubles the delay before redialing, up to the maximum.  The
+// emitter must be locked by the caller.
+func (e *UDPEmitter) increaseBackoff(now time.Time) {
+	e.backoff *= 2
+
+	if e.backoff == 0 {
+		e.backoff = minReconnectBackoff
+	} else if e.backoff > maxReconnectBackoff {
+		e.backoff = maxReconnectBackoff
+	}
+
+	e.retryAt = now.Add(e.backoff)
+}
+
+// SetResolveInterval updates how often the daemon address is re-resolved by
+// redialing.  Zero disables re-resolution.
+func (e *UDPEmitter) SetResolveInterval(interval time.Duration) {
+	e.Lock()
+	defer e.Unlock()
+	e.resolveInterval = interval
+}
+
+// SetDaemonAddress updates the daemon address from an address in one of the
+// AWS_XRAY_DAEMON_ADDRESS forms.
+func (e *UDPEmitter) SetDaemonAddress(address string) error {
+	daemonAddress, err := utils.ParseDaemonAddress(address)
+	if err != nil {
+		return err
+	}
+
+	e.setDaemonAddress(daemonAddress.UDP)
+
+	return nil
 }
 
 // SetDaemonHostAndPort updates the daemon address.
-func (e *Emitter) SetDaemonHostAndPort(host string, port string) {
+func (e *UDPEmitter) SetDaemonHostAndPort(host string, port string) {
+	e.setDaemonAddress(fmt.Sprintf("%s:%s", host, port))
+}
+
+func (e *UDPEmitter) setDaemonAddress(address string) {
 	e.Lock()
 	defer e.Unlock()
 
-	e.daemonAddress = fmt.Sprintf("%s:%s", host, port)
+	e.daemonAddress = address
+	e.backoff = 0
+	e.retryAt = time.Time{}
 
 	if e.udpConn != nil {
 		e.udpConn.Close()
@@ -62,20 +241,17 @@ func (e *Emitter) SetDaemonHostAndPort(host string, port string) {
 	}
 }
 
-// Send sends the segment packet to the daemon.
-func (e *Emitter) Send(segment *Segment) error {
-	body, err := segment.Bytes()
+// Send sends the document packet to the daemon.
+func (e *UDPEmitter) Send(doc Document) error {
+	body, err := encode(doc, maxBodySize)
 	if err != nil {
-		return fmt.Errorf("error encoding segment: %s", err.Error())
-	}
-
-	if len(body) > maxBodySize {
-		return errors.New("segment too large. >64KB")
+		return err
 	}
 
 	conn, err := e.getConnection()
 	if err != nil {
-		return fmt.Errorf("error dialing UDP: %s", err.Error())
+		recordWriteErrors(1)
+		return fmt.Errorf("error dialing daemon: %s", err.Error())
 	}
 
 	var buf bytes.Buffer
@@ -83,6 +259,14 @@ func (e *Emitter) Send(segment *Segment) error {
 	buf.Write(protocolDelimiter)
 	buf.Write(body)
 
-	_, err = conn.Write(buf.Bytes())
-	return err
+	if _, err = conn.Write(buf.Bytes()); err != nil {
+		recordWriteErrors(1)
+		e.connectionFailed(conn)
+		return err
+	}
+
+	e.connectionSucceeded()
+	recordSent(1, buf.Len())
+
+	return nil
 }
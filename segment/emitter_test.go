@@ -2,18 +2,26 @@ package segment
 
 import (
 	"fmt"
+	"github.com/goguardian/aws-xray-go/utils"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSetDaemonHostAndPort(t *testing.T) {
-	emitter := NewEmitter()
+	emitter := NewUDPEmitter()
 
-	if daemonHost == "" && daemonPort == "" && emitter.daemonAddress != "127.0.0.1:2000" {
-		t.Errorf("daemonAddress should equal 127.0.0.1:2000")
+	daemonAddress, err := utils.GetDaemonAddress()
+	if err != nil {
+		daemonAddress = &utils.DaemonAddress{UDP: utils.DefaultDaemonAddress}
 	}
 
-	if emitter.daemonAddress != fmt.Sprintf("%s:%s", daemonHost, daemonPort) {
-		t.Errorf("daemonAddress should equal %s:%s", daemonHost, daemonPort)
+	if emitter.daemonAddress != daemonAddress.UDP {
+		t.Errorf("daemonAddress should equal %s", daemonAddress.UDP)
 	}
 
 	host := "host"
@@ -33,14 +41,68 @@ func TestSetDaemonHostAndPort(t *testing.T) {
 	}
 }
 
+func TestNewUDPEmitterFromEnv(t *testing.T) {
+	defer os.Unsetenv(utils.DaemonAddressEnv)
+
+	os.Setenv(utils.DaemonAddressEnv, "tcp:127.0.0.1:2000 udp:127.0.0.1:2001")
+
+	emitter, err := NewUDPEmitterFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if emitter.daemonAddress != "127.0.0.1:2001" {
+		t.Errorf("daemonAddress should equal 127.0.0.1:2001, got %s",
+			emitter.daemonAddress)
+	}
+
+	os.Setenv(utils.DaemonAddressEnv, "invalid")
+
+	if _, err := NewUDPEmitterFromEnv(); err == nil {
+		t.Error("Invalid daemon address should error")
+	}
+
+	if emitter := NewUDPEmitter(); emitter.daemonAddress != utils.DefaultDaemonAddress {
+		t.Errorf("daemonAddress should fall back to %s, got %s",
+			utils.DefaultDaemonAddress, emitter.daemonAddress)
+	}
+}
+
+func TestSetDaemonAddress(t *testing.T) {
+	emitter := NewUDPEmitter()
+
+	if err := emitter.SetDaemonAddress("tcp:127.0.0.1:2000 udp:127.0.0.1:2001"); err != nil {
+		t.Error(err)
+	}
+
+	if emitter.daemonAddress != "127.0.0.1:2001" {
+		t.Errorf("daemonAddress should equal 127.0.0.1:2001, got %s",
+			emitter.daemonAddress)
+	}
+
+	if err := emitter.SetDaemonAddress("invalid"); err == nil {
+		t.Error("Invalid daemon address should error")
+	}
+
+	if emitter.daemonAddress != "127.0.0.1:2001" {
+		t.Error("Invalid daemon address should not update the emitter")
+	}
+}
+
 func TestSend(t *testing.T) {
-	emitter := NewEmitter()
+	defer SetTrimStrategy(getTrimStrategy()...)
+	SetTrimStrategy()
+
+	emitter := NewUDPEmitter()
 
 	seg := New("segment", nil)
 
 	// For race condition testing
+	var wg sync.WaitGroup
 	for i := 0; i < 1000; i++ {
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			emitter.SetDaemonHostAndPort("127.0.0.1", "1000")
 			emitter.Send(seg)
 		}()
@@ -50,7 +112,157 @@ func TestSend(t *testing.T) {
 		seg.AddNewSubsegment("subsegment")
 	}
 
+	wg.Wait()
+
 	if err := emitter.Send(seg); err == nil {
 		t.Error("Emitter should error for size")
 	}
 }
+
+func TestSetEmitter(t *testing.T) {
+	original := GetEmitter()
+	defer SetEmitter(original)
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+
+	if GetEmitter() != memory {
+		t.Error("Emitter should be the memory emitter")
+	}
+
+	seg := New("segment", nil)
+	seg.Traced = true
+	seg.Close()
+
+	if len(memory.Documents()) != 1 {
+		t.Errorf("Memory emitter should have 1 document, got %d",
+			len(memory.Documents()))
+	}
+}
+
+func TestUDPEmitterResolveInterval(t *testing.T) {
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	now := time.Now()
+	emitter := NewUDPEmitter()
+	emitter.now = func() time.Time { return now }
+	emitter.SetDaemonAddress(listener.LocalAddr().String())
+	emitter.SetResolveInterval(time.Minute)
+
+	if err := emitter.Send(New("segment", nil)); err != nil {
+		t.Fatal(err)
+	}
+	conn := emitter.udpConn
+
+	now = now.Add(30 * time.Second)
+	emitter.Send(New("segment", nil))
+	if emitter.udpConn != conn {
+		t.Error("Connection should be reused within the resolve interval")
+	}
+
+	now = now.Add(time.Minute)
+	emitter.Send(New("segment", nil))
+	if emitter.udpConn == conn {
+		t.Error("Connection should be redialed after the resolve interval")
+	}
+}
+
+func TestUDPEmitterReconnectBackoff(t *testing.T) {
+	// Find a local port with nothing listening on it.
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.LocalAddr().String()
+	listener.Close()
+
+	now := time.Now()
+	emitter := NewUDPEmitter()
+	emitter.now = func() time.Time { return now }
+	emitter.SetDaemonAddress(address)
+
+	// Connected UDP sockets report the refused connection on a later write.
+	for i := 0; i < 10 && emitter.backoff == 0; i++ {
+		emitter.Send(New("segment", nil))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if emitter.backoff != minReconnectBackoff {
+		t.Fatalf("Backoff should be %s after a write error, got %s",
+			minReconnectBackoff, emitter.backoff)
+	}
+
+	if emitter.udpConn != nil {
+		t.Error("Connection should be closed after a write error")
+	}
+
+	if err := emitter.Send(New("segment", nil)); err == nil {
+		t.Error("Send should error while backing off")
+	}
+
+	if emitter.udpConn != nil {
+		t.Error("Connection should not be redialed while backing off")
+	}
+
+	now = now.Add(minReconnectBackoff)
+	emitter.Send(New("segment", nil))
+
+	if emitter.udpConn == nil {
+		t.Error("Connection should be redialed after the backoff")
+	}
+
+	emitter.Lock()
+	emitter.increaseBackoff(now)
+	for i := 0; i < 20; i++ {
+		emitter.increaseBackoff(now)
+	}
+	emitter.Unlock()
+
+	if emitter.backoff != maxReconnectBackoff {
+		t.Errorf("Backoff should be limited to %s, got %s",
+			maxReconnectBackoff, emitter.backoff)
+	}
+}
+
+func TestUDPEmitterUnixgram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xray")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "xray.sock")
+	listener, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	emitter := NewUDPEmitter()
+	if err := emitter.SetDaemonAddress(utils.UnixgramScheme + path); err != nil {
+		t.Fatal(err)
+	}
+
+	seg := New("segment", nil)
+	if err := emitter.Send(seg); err != nil {
+		t.Fatal(err)
+	}
+
+	packet := make([]byte, maxBodySize+len(protocolHeader)+1)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := listener.Read(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := seg.Bytes()
+	expected := string(protocolHeader) + string(protocolDelimiter) + string(body)
+	if string(packet[:n]) != expected {
+		t.Errorf("Expected packet '%s', got '%s'", expected, packet[:n])
+	}
+}
@@ -0,0 +1,155 @@
+package segment
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileEmitterConfig represents the configuration of a FileEmitter.
+type FileEmitterConfig struct {
+	// Path is the file documents are written to.
+	Path string
+	// MaxBytes is the size at which the file is rotated.  Zero disables
+	// rotation.
+	MaxBytes int64
+	// MaxBackups is the number of rotated files kept, named Path.1 (newest)
+	// through Path.MaxBackups (oldest).
+	MaxBackups int
+}
+
+// FileEmitter writes every document it is sent to a file as a single line of
+// JSON, rotating the file by size.
+type FileEmitter struct {
+	*WriterEmitter
+	file *RotatingFile
+}
+
+// NewFileEmitter opens the file and creates a new emitter writing to it.
+func NewFileEmitter(config FileEmitterConfig) (*FileEmitter, error) {
+	file, err := OpenRotatingFile(config.Path, config.MaxBytes, config.MaxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileEmitter{WriterEmitter: NewWriterEmitter(file), file: file}, nil
+}
+
+// Close closes the file.
+func (e *FileEmitter) Close(ctx context.Context) error {
+	return e.file.Close()
+}
+
+// RotatingFile represents an io.Writer appending to a file that is rotated
+// once it reaches a maximum size.  A single write is never split across
+// files.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+
+	sync.Mutex
+}
+
+// OpenRotatingFile opens the file for appending, creating it if needed.
+func OpenRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening trace file: %s", err.Error())
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error opening trace file: %s", err.Error())
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	return nil
+}
+
+// Write writes p to the file, rotating it first if p would exceed the maximum
+// size.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+// rotate moves the current file to the first backup, shifting older backups
+// and removing the oldest, then opens a new file.  If the file cannot be
+// moved, the current file is reopened so later writes continue, and rotation
+// is retried on the next write.
+func (r *RotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	if err := r.shift(); err != nil {
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+
+		return fmt.Errorf("error rotating trace file: %s", err.Error())
+	}
+
+	return r.open()
+}
+
+// shift moves the file to the first backup, shifting older backups and
+// removing the oldest, or removes the file when no backups are kept.
+func (r *RotatingFile) shift() error {
+	if r.maxBackups <= 0 {
+		return os.Remove(r.path)
+	}
+
+	for i := r.maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i),
+			fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+
+	return os.Rename(r.path, r.path+".1")
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
@@ -0,0 +1,143 @@
+package segment
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileEmitter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+
+	emitter, err := NewFileEmitter(FileEmitterConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := emitter.Send(New("segment", nil)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := emitter.Close(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	if lines := readLines(t, path); lines != 3 {
+		t.Errorf("Expected 3 lines, got %d", lines)
+	}
+
+	if err := emitter.Send(New("segment", nil)); err == nil {
+		t.Error("Closed file emitter should error")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+
+	file, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+
+	for name, content := range expect {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if string(data) != content {
+			t.Errorf("Expected '%s' to contain %q, got %q", name, content, data)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("Only 2 backups should be kept")
+	}
+}
+
+func TestRotatingFileRotateError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+
+	file, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	// A non-empty directory in place of the backup makes the rename fail.
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Write([]byte("second\n")); err == nil {
+		t.Error("Write should return the rotation error")
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Write([]byte("third\n")); err != nil {
+		t.Fatalf("Writing should continue after a rotation error: %s", err)
+	}
+
+	expect := map[string]string{
+		path:        "third\n",
+		path + ".1": "first\n",
+	}
+
+	for name, content := range expect {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if string(data) != content {
+			t.Errorf("Expected '%s' to contain %q, got %q", name, content, data)
+		}
+	}
+}
+
+func readLines(t *testing.T, path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		decoded := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &decoded); err != nil {
+			t.Error(err)
+		}
+		lines++
+	}
+
+	return lines
+}
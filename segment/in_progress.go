@@ -0,0 +1,108 @@
+package segment
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+var (
+	inProgressConfig      = InProgressConfig{}
+	inProgressConfigMutex = &sync.RWMutex{}
+)
+
+// InProgressConfig represents whether in-progress segment documents are sent
+// before a segment is closed.  In-progress documents make long running work
+// visible while it runs, and leave a record of work that never closed.
+type InProgressConfig struct {
+	// EmitOnStart sends an in-progress document when a segment is created.
+	EmitOnStart bool
+	// HeartbeatInterval, if greater than zero, resends the in-progress
+	// document at the interval until the segment is closed.
+	HeartbeatInterval time.Duration
+}
+
+// SetInProgressConfig updates the in-progress segment settings used for new
+// segments.
+func SetInProgressConfig(config InProgressConfig) {
+	inProgressConfigMutex.Lock()
+	defer inProgressConfigMutex.Unlock()
+	inProgressConfig = config
+}
+
+func getInProgressConfig() InProgressConfig {
+	inProgressConfigMutex.RLock()
+	defer inProgressConfigMutex.RUnlock()
+	return inProgressConfig
+}
+
+// startInProgress sends the in-progress document and starts the heartbeat
+// according to the in-progress settings.
+func (s *Segment) startInProgress() {
+	config := getInProgressConfig()
+
+	s.Lock()
+	traced := s.Traced
+	if traced && config.HeartbeatInterval > 0 {
+		s.heartbeatDone = make(chan struct{})
+		go s.heartbeat(config.HeartbeatInterval, s.heartbeatDone)
+	}
+	s.Unlock()
+
+	if traced && config.EmitOnStart {
+		s.sendInProgress()
+	}
+}
+
+// encodedDocument is a document encoded ahead of sending.
+type encodedDocument []byte
+
+func (d encodedDocument) Bytes() ([]byte, error) {
+	return d, nil
+}
+
+// sendInProgress sends the in-progress document of the segment, unless it
+// has been closed.  The segment is encoded before sending, since emitters that
+// send later would otherwise encode it after it is closed.
+func (s *Segment) sendInProgress() {
+	s.RLock()
+	inProgress := s.InProgress
+	body, err := json.Marshal(s)
+	s.RUnlock()
+
+	if !inProgress {
+		return
+	}
+
+	if err != nil {
+		recordEncodeError()
+		return
+	}
+
+	GetEmitter().Send(encodedDocument(body))
+}
+
+// heartbeat sends the in-progress document at every interval until done is
+// closed.
+func (s *Segment) heartbeat(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sendInProgress()
+		case <-done:
+			return
+		}
+	}
+}
+
+// stopHeartbeat stops the heartbeat of the segment.  The segment must be
+// locked by the caller.
+func (s *Segment) stopHeartbeat() {
+	if s.heartbeatDone != nil {
+		close(s.heartbeatDone)
+		s.heartbeatDone = nil
+	}
+}
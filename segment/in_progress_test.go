@@ -0,0 +1,168 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/goguardian/aws-xray-go/utils"
+	"testing"
+	"time"
+)
+
+func TestInProgressEmitOnStart(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetInProgressConfig(getInProgressConfig())
+	defer SetSamplingStrategy(getSamplingStrategy())
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+	SetSampler(utils.NewSampler(0, 1))
+	SetInProgressConfig(InProgressConfig{EmitOnStart: true})
+
+	seg := New("segment", nil)
+
+	documents := memory.Documents()
+	if len(documents) != 1 {
+		t.Fatalf("Expected 1 in-progress document, got %d", len(documents))
+	}
+
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(documents[0], &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded["in_progress"] != true {
+		t.Error("Document should be in progress")
+	}
+
+	if _, ok := decoded["end_time"]; ok {
+		t.Error("In-progress document should not have an end time")
+	}
+
+	seg.Close()
+
+	if len(memory.Documents()) != 2 {
+		t.Errorf("Expected 2 documents after close, got %d",
+			len(memory.Documents()))
+	}
+}
+
+func TestInProgressHeartbeat(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetInProgressConfig(getInProgressConfig())
+	defer SetSamplingStrategy(getSamplingStrategy())
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+	SetSampler(utils.NewSampler(0, 1))
+	SetInProgressConfig(InProgressConfig{HeartbeatInterval: time.Millisecond})
+
+	seg := New("segment", nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(memory.Documents()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if len(memory.Documents()) < 2 {
+		t.Error("Heartbeat should send in-progress documents")
+	}
+
+	seg.Close()
+	time.Sleep(10 * time.Millisecond)
+	count := len(memory.Documents())
+
+	time.Sleep(10 * time.Millisecond)
+
+	if len(memory.Documents()) != count {
+		t.Error("Heartbeat should stop when the segment is closed")
+	}
+}
+
+func TestInProgressSubsegment(t *testing.T) {
+	seg := New("segment", nil)
+	subseg := NewSubsegment("subsegment")
+	seg.AddSubsegment(subseg)
+
+	decoded := struct {
+		Subsegments []map[string]interface{} `json:"subsegments"`
+	}{}
+
+	body, _ := seg.Bytes()
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Subsegments[0]["in_progress"] != true {
+		t.Error("Open subsegment should be in progress")
+	}
+
+	if _, ok := decoded.Subsegments[0]["end_time"]; ok {
+		t.Error("Open subsegment should not have an end time")
+	}
+
+	subseg.Close(nil, "")
+
+	decoded.Subsegments = nil
+	body, _ = seg.Bytes()
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := decoded.Subsegments[0]["in_progress"]; ok {
+		t.Error("Closed subsegment should not be in progress")
+	}
+
+	if _, ok := decoded.Subsegments[0]["end_time"]; !ok {
+		t.Error("Closed subsegment should have an end time")
+	}
+}
+
+func TestInProgressAsyncEmitter(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetInProgressConfig(getInProgressConfig())
+	defer SetSamplingStrategy(getSamplingStrategy())
+
+	blocking := &blockingEmitter{
+		release: make(chan struct{}),
+		memory:  NewMemoryEmitter(),
+	}
+	async := NewAsyncEmitter(blocking, AsyncEmitterConfig{})
+	SetEmitter(async)
+	SetSampler(utils.NewSampler(0, 1))
+	SetInProgressConfig(InProgressConfig{EmitOnStart: true})
+
+	seg := New("segment", nil)
+	seg.Close()
+	close(blocking.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := async.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	documents := blocking.memory.Documents()
+	if len(documents) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(documents))
+	}
+
+	inProgress := 0
+	for _, document := range documents {
+		decoded := map[string]interface{}{}
+		if err := json.Unmarshal(document, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded["in_progress"] == true {
+			inProgress++
+		}
+	}
+
+	if inProgress != 1 {
+		t.Errorf("Expected 1 in-progress document, got %d", inProgress)
+	}
+}
@@ -0,0 +1,51 @@
+package segment
+
+import "sync"
+
+// MemoryEmitter records the JSON body of every document it is sent.  It is
+// intended for tests and local development where the emitted trace data
+// needs to be inspected.
+type MemoryEmitter struct {
+	documents [][]byte
+
+	sync.RWMutex
+}
+
+// NewMemoryEmitter creates a new, empty in-memory emitter.
+func NewMemoryEmitter() *MemoryEmitter {
+	return &MemoryEmitter{}
+}
+
+// Send encodes the document and records it.
+func (e *MemoryEmitter) Send(doc Document) error {
+	body, err := encode(doc, 0)
+	if err != nil {
+		return err
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	e.documents = append(e.documents, body)
+
+	return nil
+}
+
+// Documents returns the JSON bodies of all recorded documents in the order
+// they were sent.
+func (e *MemoryEmitter) Documents() [][]byte {
+	e.RLock()
+	defer e.RUnlock()
+
+	documents := make([][]byte, len(e.documents))
+	copy(documents, e.documents)
+
+	return documents
+}
+
+// Reset discards all recorded documents.
+func (e *MemoryEmitter) Reset() {
+	e.Lock()
+	defer e.Unlock()
+	e.documents = nil
+}
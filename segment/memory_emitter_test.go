@@ -0,0 +1,40 @@
+package segment
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMemoryEmitter(t *testing.T) {
+	emitter := NewMemoryEmitter()
+
+	seg := New("segment", nil)
+	seg.AddNewSubsegment("subsegment")
+
+	if err := emitter.Send(seg); err != nil {
+		t.Error(err)
+	}
+
+	documents := emitter.Documents()
+	if len(documents) != 1 {
+		t.Fatalf("Memory emitter should have 1 document, got %d", len(documents))
+	}
+
+	decoded := &Segment{}
+	if err := json.Unmarshal(documents[0], decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.ID != seg.ID {
+		t.Errorf("Document ID should be %s, got %s", seg.ID, decoded.ID)
+	}
+
+	if len(decoded.Subsegments) != 1 {
+		t.Error("Document should have 1 subsegment")
+	}
+
+	emitter.Reset()
+	if len(emitter.Documents()) != 0 {
+		t.Error("Memory emitter should have no documents after reset")
+	}
+}
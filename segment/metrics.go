@@ -0,0 +1,100 @@
+package segment
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+const (
+	// ExpvarName represents the name under which emitter statistics are
+	// published with expvar.
+	ExpvarName = "xray_emitter"
+)
+
+var stats emitterStats
+
+// EmitterStats represents counters describing the health of segment emission
+// since the process started.  Documents sent to the daemon or the X-Ray API
+// are counted separately from documents written by a WriterEmitter or
+// FileEmitter, so a document sent to both is not counted twice.
+type EmitterStats struct {
+	SegmentsSent     int64 `json:"segments_sent"`
+	BytesSent        int64 `json:"bytes_sent"`
+	DroppedOversize  int64 `json:"dropped_oversize"`
+	DroppedQueueFull int64 `json:"dropped_queue_full"`
+	Trimmed          int64 `json:"trimmed"`
+	EncodeErrors     int64 `json:"encode_errors"`
+	WriteErrors      int64 `json:"write_errors"`
+	DocumentsWritten int64 `json:"documents_written"`
+	BytesWritten     int64 `json:"bytes_written"`
+	WriterErrors     int64 `json:"writer_errors"`
+}
+
+type emitterStats struct {
+	segmentsSent     int64
+	bytesSent        int64
+	droppedOversize  int64
+	droppedQueueFull int64
+	trimmed          int64
+	encodeErrors     int64
+	writeErrors      int64
+	documentsWritten int64
+	bytesWritten     int64
+	writerErrors     int64
+}
+
+func init() {
+	expvar.Publish(ExpvarName, expvar.Func(func() interface{} {
+		return GetEmitterStats()
+	}))
+}
+
+// GetEmitterStats returns the current emitter statistics.
+func GetEmitterStats() EmitterStats {
+	return EmitterStats{
+		SegmentsSent:     atomic.LoadInt64(&stats.segmentsSent),
+		BytesSent:        atomic.LoadInt64(&stats.bytesSent),
+		DroppedOversize:  atomic.LoadInt64(&stats.droppedOversize),
+		DroppedQueueFull: atomic.LoadInt64(&stats.droppedQueueFull),
+		Trimmed:          atomic.LoadInt64(&stats.trimmed),
+		EncodeErrors:     atomic.LoadInt64(&stats.encodeErrors),
+		WriteErrors:      atomic.LoadInt64(&stats.writeErrors),
+		DocumentsWritten: atomic.LoadInt64(&stats.documentsWritten),
+		BytesWritten:     atomic.LoadInt64(&stats.bytesWritten),
+		WriterErrors:     atomic.LoadInt64(&stats.writerErrors),
+	}
+}
+
+func recordSent(documents int, bytes int) {
+	atomic.AddInt64(&stats.segmentsSent, int64(documents))
+	atomic.AddInt64(&stats.bytesSent, int64(bytes))
+}
+
+func recordDroppedOversize() {
+	atomic.AddInt64(&stats.droppedOversize, 1)
+}
+
+func recordDroppedQueueFull() {
+	atomic.AddInt64(&stats.droppedQueueFull, 1)
+}
+
+func recordTrimmed() {
+	atomic.AddInt64(&stats.trimmed, 1)
+}
+
+func recordEncodeError() {
+	atomic.AddInt64(&stats.encodeErrors, 1)
+}
+
+func recordWriteErrors(documents int) {
+	atomic.AddInt64(&stats.writeErrors, int64(documents))
+}
+
+func recordWritten(bytes int) {
+	atomic.AddInt64(&stats.documentsWritten, 1)
+	atomic.AddInt64(&stats.bytesWritten, int64(bytes))
+}
+
+func recordWriterError() {
+	atomic.AddInt64(&stats.writerErrors, 1)
+}
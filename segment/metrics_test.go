@@ -0,0 +1,106 @@
+package segment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingWriter is an io.Writer that always errors.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestEmitterStats(t *testing.T) {
+	before := GetEmitterStats()
+
+	var buf bytes.Buffer
+	NewWriterEmitter(&buf).Send(New("segment", nil))
+	NewWriterEmitter(failingWriter{}).Send(New("segment", nil))
+
+	seg := New("segment", nil)
+	seg.AddAnnotation("large", strings.Repeat("a", maxBodySize))
+	NewUDPEmitter().Send(seg)
+
+	// Other tests may leave emitters sending in the background, so the
+	// counters are checked as lower bounds.
+	after := GetEmitterStats()
+
+	if after.DocumentsWritten-before.DocumentsWritten < 1 {
+		t.Errorf("Expected at least 1 document written, got %d",
+			after.DocumentsWritten-before.DocumentsWritten)
+	}
+
+	if after.BytesWritten-before.BytesWritten < int64(buf.Len()) {
+		t.Errorf("Expected at least %d bytes written, got %d", buf.Len(),
+			after.BytesWritten-before.BytesWritten)
+	}
+
+	if after.WriterErrors-before.WriterErrors < 1 {
+		t.Errorf("Expected at least 1 writer error, got %d",
+			after.WriterErrors-before.WriterErrors)
+	}
+
+	if after.DroppedOversize-before.DroppedOversize < 1 {
+		t.Errorf("Expected at least 1 oversize drop, got %d",
+			after.DroppedOversize-before.DroppedOversize)
+	}
+
+	published := expvar.Get(ExpvarName)
+	if published == nil {
+		t.Fatal("Emitter stats should be published with expvar")
+	}
+
+	decoded := EmitterStats{}
+	if err := json.Unmarshal([]byte(published.String()), &decoded); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmitterStatsMultiEmitter(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var buf bytes.Buffer
+	emitter := NewMultiEmitter(newUDPEmitter(listener.LocalAddr().String()),
+		NewWriterEmitter(&buf))
+
+	before := GetEmitterStats()
+	if err := emitter.Send(New("segment", nil)); err != nil {
+		t.Fatal(err)
+	}
+	after := GetEmitterStats()
+
+	packet := make([]byte, maxBodySize)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFrom(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := after.SegmentsSent - before.SegmentsSent; sent != 1 {
+		t.Errorf("Expected 1 segment sent, got %d", sent)
+	}
+
+	if sent := after.BytesSent - before.BytesSent; sent != int64(n) {
+		t.Errorf("Expected %d bytes sent, got %d", n, sent)
+	}
+
+	if written := after.DocumentsWritten - before.DocumentsWritten; written != 1 {
+		t.Errorf("Expected 1 document written, got %d", written)
+	}
+
+	if written := after.BytesWritten - before.BytesWritten; written != int64(buf.Len()) {
+		t.Errorf("Expected %d bytes written, got %d", buf.Len(), written)
+	}
+}
@@ -0,0 +1,46 @@
+package segment
+
+import (
+	"context"
+)
+
+// MultiEmitter sends every document to each of a list of emitters, for
+// example to write a local copy of the segments sent to the daemon.
+type MultiEmitter struct {
+	emitters []Emitter
+}
+
+// NewMultiEmitter creates a new emitter sending to all of the emitters.
+func NewMultiEmitter(emitters ...Emitter) *MultiEmitter {
+	return &MultiEmitter{emitters: emitters}
+}
+
+// Send sends the document to every emitter, returning the first error.
+func (m *MultiEmitter) Send(doc Document) error {
+	var err error
+	for _, emitter := range m.emitters {
+		if sendErr := emitter.Send(doc); sendErr != nil && err == nil {
+			err = sendErr
+		}
+	}
+
+	return err
+}
+
+// Flush flushes every emitter that holds documents to be sent later,
+// returning the first error.
+func (m *MultiEmitter) Flush(ctx context.Context) error {
+	var err error
+	for _, emitter := range m.emitters {
+		flusher, ok := emitter.(Flusher)
+		if !ok {
+			continue
+		}
+
+		if flushErr := flusher.Flush(ctx); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,30 @@
+package segment
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMultiEmitter(t *testing.T) {
+	memory := NewMemoryEmitter()
+	emitter := NewMultiEmitter(NewWriterEmitter(failingWriter{}), memory)
+
+	if err := emitter.Send(New("segment", nil)); err == nil {
+		t.Error("Multi emitter should return the failing emitter error")
+	}
+
+	if len(memory.Documents()) != 1 {
+		t.Error("Multi emitter should send to every emitter")
+	}
+
+	var buf bytes.Buffer
+	emitter = NewMultiEmitter(NewWriterEmitter(&buf), memory)
+
+	if err := emitter.Send(New("segment", nil)); err != nil {
+		t.Error(err)
+	}
+
+	if buf.Len() == 0 || len(memory.Documents()) != 2 {
+		t.Error("Multi emitter should send to every emitter")
+	}
+}
@@ -6,21 +6,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/goguardian/aws-xray-go/attributes"
+	"github.com/goguardian/aws-xray-go/sampling"
 	"github.com/goguardian/aws-xray-go/utils"
 	"os"
 	"sync"
 )
 
 var (
-	emitter      = NewEmitter()
-	sampler      = utils.NewSampler(10, 0.05)
-	samplerMutex = &sync.RWMutex{}
+	emitter               Emitter           = NewUDPEmitter()
+	emitterMutex                            = &sync.RWMutex{}
+	samplingStrategy      sampling.Strategy = sampling.NewSamplerStrategy(10, 0.05)
+	samplingStrategyMutex                   = &sync.RWMutex{}
 )
 
 // Segment represents a segment.
 type Segment struct {
 	StartTime   float64                `json:"start_time"`
-	EndTime     float64                `json:"end_time"`
+	EndTime     float64                `json:"end_time,omitempty"`
 	InProgress  bool                   `json:"in_progress"`
 	Throttle    bool                   `json:"throttle"`
 	Fault       bool                   `json:"fault"`
@@ -32,11 +34,16 @@ type Segment struct {
 	Name        string                 `json:"name"`
 	HTTP        *attributes.Local      `json:"http,omitempty"`
 	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	Metadata    *metadata              `json:"metadata,omitempty"`
 	Subsegments []*Subsegment          `json:"subsegments,omitempty"`
 	Service     *service               `json:"service,omitempty"`
 	Cause       *cause                 `json:"cause,omitempty"`
 	exception   *exception
 
+	heartbeatDone chan struct{}
+	flushed       bool
+	upstream      *utils.TraceHeader
+
 	sync.RWMutex
 }
 
@@ -58,14 +65,63 @@ type cause struct {
 
 // New creates a new segment.
 func New(name string, ctx context.Context) *Segment {
-	startTime := utils.CurrentTimeSecond()
-
 	traceID, parentID, sampled := utils.GetIDsFromContext(ctx)
 
+	upstream, _ := utils.TraceHeaderFromContext(ctx)
+
+	// A segment already in the context continues its trace.
+	if parent, ok := FromContext(ctx); ok {
+		parent.RLock()
+		traceID, parentID, sampled = parent.TraceID, parent.ParentID, "0"
+		if parent.Traced {
+			sampled = "1"
+		}
+		upstream = parent.upstream
+		parent.RUnlock()
+	}
+
+	request, _ := sampling.FromContext(ctx)
+
+	return newSegment(name, traceID, parentID, sampled, upstream, request,
+		utils.CurrentTimeSecond())
+}
+
+// NewWithTraceHeader creates a new segment continuing the trace of a trace
+// header, starting at a time in seconds since the epoch.  A nil header, or a
+// header without a root trace ID, starts a new trace, and a zero start time is
+// the current time.
+func NewWithTraceHeader(
+	name string,
+	header *utils.TraceHeader,
+	startTime float64,
+) *Segment {
+
+	if startTime == 0 {
+		startTime = utils.CurrentTimeSecond()
+	}
+
+	if header == nil {
+		return newSegment(name, "", "", "", nil, nil, startTime)
+	}
+
+	return newSegment(name, header.Root, header.Parent, header.Sampled, header,
+		nil, startTime)
+}
+
+func newSegment(
+	name string,
+	traceID string,
+	parentID string,
+	sampled string,
+	upstream *utils.TraceHeader,
+	request *sampling.Request,
+	startTime float64,
+) *Segment {
+
 	if traceID == "" {
 		traceIDSuffix := make([]byte, 12)
 		rand.Read(traceIDSuffix)
-		traceID = fmt.Sprintf("1-%x-%x", int64(startTime), traceIDSuffix)
+		traceID = fmt.Sprintf("1-%08x-%x", int64(startTime), traceIDSuffix)
 	}
 
 	idBytes := make([]byte, 8)
@@ -79,9 +135,16 @@ func New(name string, ctx context.Context) *Segment {
 		Name:       name,
 		StartTime:  startTime,
 		InProgress: true,
+		upstream:   upstream,
 	}
 
-	seg.resolveSampling(sampled)
+	seg.resolveSampling(sampled, request)
+
+	if seg.Traced && !register(seg) {
+		seg.Traced = false
+	}
+
+	seg.startInProgress()
 
 	return seg
 }
@@ -212,6 +275,7 @@ func (s *Segment) Close() error {
 	}
 
 	s.InProgress = false
+	s.stopHeartbeat()
 
 	s.Unlock()
 
@@ -241,34 +305,101 @@ func (s *Segment) DecrementCounter() error {
 	return nil
 }
 
-// Flush read locks and sends the segment to the daemon.
-func (s *Segment) Flush() error {
+// DownstreamHeader returns the trace header to send with a downstream call
+// made by the segment or one of its subsegments.  The lineage, custom keys and
+// W3C tracestate of the incoming trace header are forwarded.
+func (s *Segment) DownstreamHeader(parentID string) *utils.TraceHeader {
 	s.RLock()
 	defer s.RUnlock()
 
-	if !s.Traced {
-		return nil
+	header := &utils.TraceHeader{
+		Root:    s.TraceID,
+		Parent:  parentID,
+		Sampled: "0",
+	}
+
+	if s.Traced {
+		header.Sampled = "1"
+	}
+
+	if s.upstream != nil {
+		header.Lineage = s.upstream.Lineage
+		header.Data = s.upstream.Data
+		header.TraceState = s.upstream.TraceState
+	}
+
+	return header
+}
+
+// ResponseHeader returns the trace header to send with the response to the
+// request traced by the segment.  The sampling decision is included when the
+// caller requested it with "Sampled=?".
+func (s *Segment) ResponseHeader() *utils.TraceHeader {
+	s.RLock()
+	defer s.RUnlock()
+
+	header := &utils.TraceHeader{Root: s.TraceID}
+
+	if s.upstream != nil && s.upstream.Sampled == "?" {
+		header.Sampled = "0"
+		if s.Traced {
+			header.Sampled = "1"
+		}
 	}
 
-	return emitter.Send(s)
+	return header
 }
 
-// resolveSampling determines whether to sample the segment
-func (s *Segment) resolveSampling(sampled string) {
+// Flush streams any subsegments over the streaming thresholds, then sends the
+// segment to the daemon.  The segment is only sent once.  It is not locked
+// while sending, since emitters lock it to encode it.
+func (s *Segment) Flush() error {
+	streamErr := s.streamSubsegments()
+
 	s.Lock()
-	defer s.Unlock()
+	flushed := s.flushed
+	traced := s.Traced
+	s.flushed = true
+	s.Unlock()
+
+	unregister(s)
+
+	if !traced || flushed {
+		return nil
+	}
 
-	if sampled == "1" {
-		s.Traced = true
-		return
+	if err := GetEmitter().Send(s); err != nil {
+		return err
 	}
 
-	if sampled == "0" {
-		s.Traced = false
-		return
+	return streamErr
+}
+
+// resolveSampling determines whether to sample the segment.  Without an
+// upstream decision, the sampling strategy decides from the request, which
+// defaults to the segment name as the service name.
+func (s *Segment) resolveSampling(sampled string, request *sampling.Request) {
+	if sampled != "1" && sampled != "0" {
+		r := sampling.Request{}
+		if request != nil {
+			r = *request
+		}
+
+		if r.ServiceName == "" {
+			r.ServiceName = s.Name
+		}
+
+		traced := getSamplingStrategy().ShouldTrace(&r)
+		sampled = "0"
+		if traced {
+			sampled = "1"
+		}
 	}
 
-	s.Traced = sampler.IsSampled()
+	s.Lock()
+	defer s.Unlock()
+
+	s.Traced = sampled == "1"
 }
 
 // String returns the segment as a JSON encode string
@@ -279,7 +410,19 @@ func (s *Segment) String() (string, error) {
 
 // SetSampler updates the sampler used for segment sampling.
 func SetSampler(s *utils.Sampler) {
-	samplerMutex.Lock()
-	defer samplerMutex.Unlock()
-	sampler = s
+	SetSamplingStrategy(&sampling.SamplerStrategy{Sampler: s})
+}
+
+// SetSamplingStrategy updates the strategy deciding whether segments without
+// an upstream sampling decision are traced.
+func SetSamplingStrategy(strategy sampling.Strategy) {
+	samplingStrategyMutex.Lock()
+	defer samplingStrategyMutex.Unlock()
+	samplingStrategy = strategy
+}
+
+func getSamplingStrategy() sampling.Strategy {
+	samplingStrategyMutex.RLock()
+	defer samplingStrategyMutex.RUnlock()
+	return samplingStrategy
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"github.com/goguardian/aws-xray-go/attributes"
+	"github.com/goguardian/aws-xray-go/sampling"
 	"github.com/goguardian/aws-xray-go/utils"
 	"net/http"
 	"net/url"
@@ -14,14 +15,14 @@ import (
 func TestNewSegment(t *testing.T) {
 	req1 := &http.Request{
 		Header: http.Header{
-			utils.XRayHeader: []string{"Root=123; Parent=456; Sampled=1"},
+			utils.XRayHeader: []string{"Root=1-5759e988-bd862e3fe1be46a994272793; Parent=53995c3f42cd8ad8; Sampled=1"},
 		},
 	}
 	req1 = req1.WithContext(utils.ContextFromHeaders(req1))
 
 	req2 := &http.Request{
 		Header: http.Header{
-			utils.XRayHeader: []string{"Root=123; Parent=456; Sampled=0"},
+			utils.XRayHeader: []string{"Root=1-5759e988-bd862e3fe1be46a994272793; Parent=53995c3f42cd8ad8; Sampled=0"},
 		},
 	}
 	req2 = req2.WithContext(utils.ContextFromHeaders(req2))
@@ -39,14 +40,14 @@ func TestNewSegment(t *testing.T) {
 		{
 			name:           "test",
 			context:        req1.Context(),
-			expectTraceID:  "123",
-			expectParentID: "456",
+			expectTraceID:  "1-5759e988-bd862e3fe1be46a994272793",
+			expectParentID: "53995c3f42cd8ad8",
 		},
 		{
 			name:           "test",
 			context:        req2.Context(),
-			expectTraceID:  "123",
-			expectParentID: "456",
+			expectTraceID:  "1-5759e988-bd862e3fe1be46a994272793",
+			expectParentID: "53995c3f42cd8ad8",
 		},
 	}
 
@@ -178,3 +179,111 @@ func TestSetSampler(t *testing.T) {
 		t.Errorf("Trace count should be %d, not %d", 1, traceCount)
 	}
 }
+
+// recordingStrategy is a sampling strategy that records the requests it is
+// asked about.
+type recordingStrategy struct {
+	requests []sampling.Request
+	traced   bool
+}
+
+func (s *recordingStrategy) ShouldTrace(request *sampling.Request) bool {
+	s.requests = append(s.requests, *request)
+	return s.traced
+}
+
+func TestSetSamplingStrategy(t *testing.T) {
+	defer SetSamplingStrategy(getSamplingStrategy())
+
+	strategy := &recordingStrategy{traced: true}
+	SetSamplingStrategy(strategy)
+
+	ctx := sampling.NewContext(context.Background(), &sampling.Request{
+		Host:    "example.com",
+		Method:  "GET",
+		URLPath: "/health",
+	})
+
+	if seg := New("service", ctx); !seg.Traced {
+		t.Error("Segment should be traced by the strategy")
+	}
+
+	strategy.traced = false
+	if seg := New("other", nil); seg.Traced {
+		t.Error("Segment should not be traced by the strategy")
+	}
+
+	header := &utils.TraceHeader{Root: "1-5759e988-bd862e3fe1be46a994272793", Sampled: "1"}
+	if seg := NewWithTraceHeader("upstream", header, 0); !seg.Traced {
+		t.Error("Upstream sampling decision should take precedence")
+	}
+
+	expected := []sampling.Request{
+		{Host: "example.com", Method: "GET", URLPath: "/health",
+			ServiceName: "service"},
+		{ServiceName: "other"},
+	}
+
+	if len(strategy.requests) != len(expected) {
+		t.Fatalf("Expected %d sampling requests, got %d", len(expected),
+			len(strategy.requests))
+	}
+
+	for i, request := range strategy.requests {
+		if request != expected[i] {
+			t.Errorf("Expected sampling request '%+v', got '%+v'", expected[i],
+				request)
+		}
+	}
+}
+
+func TestResponseHeader(t *testing.T) {
+	traceID := "1-5759e988-bd862e3fe1be46a994272793"
+
+	tests := []struct {
+		header        string
+		traced        bool
+		expectSampled string
+	}{
+		{header: "Root=" + traceID + ";Sampled=?", traced: true, expectSampled: "1"},
+		{header: "Root=" + traceID + ";Sampled=?", traced: false, expectSampled: "0"},
+		{header: "Root=" + traceID + ";Sampled=1", traced: true, expectSampled: ""},
+		{header: "Root=" + traceID, traced: true, expectSampled: ""},
+	}
+
+	for _, test := range tests {
+		req := &http.Request{Header: http.Header{utils.XRayHeader: {test.header}}}
+
+		seg := New("segment", utils.ContextFromHeaders(req))
+		seg.Traced = test.traced
+
+		header := seg.ResponseHeader()
+		if header.Root != traceID {
+			t.Errorf("Expected root '%s', got '%s'", traceID, header.Root)
+		}
+
+		if header.Sampled != test.expectSampled {
+			t.Errorf("Expected sampled '%s' for '%s', got '%s'",
+				test.expectSampled, test.header, header.Sampled)
+		}
+	}
+}
+
+func TestNewWithTraceHeader(t *testing.T) {
+	header := &utils.TraceHeader{
+		Root:    "1-5759e988-bd862e3fe1be46a994272793",
+		Parent:  "53995c3f42cd8ad8",
+		Sampled: "0",
+	}
+
+	seg := NewWithTraceHeader("segment", header, 1465510280)
+	if seg.TraceID != header.Root || seg.ParentID != header.Parent ||
+		seg.StartTime != 1465510280 || seg.Traced {
+		t.Error("Segment should continue the trace header from the start time")
+	}
+
+	seg = NewWithTraceHeader("segment", nil, 0)
+	if !utils.ValidTraceID(seg.TraceID) || seg.StartTime == 0 {
+		t.Errorf("Segment should start a new trace now, got '%s'", seg.TraceID)
+	}
+}
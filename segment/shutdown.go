@@ -0,0 +1,164 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"github.com/goguardian/aws-xray-go/utils"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOpenSegmentMaxAge = 10 * time.Minute
+	openSegmentsPruneEvery   = 30 * time.Second
+)
+
+var (
+	errShutdown       = errors.New("closed by shutdown")
+	openSegments      = map[*Segment]time.Time{}
+	openSegmentMaxAge = defaultOpenSegmentMaxAge
+	openSegmentsPrune time.Time
+	shuttingDown      bool
+	openSegmentsMutex = &sync.Mutex{}
+)
+
+// SetOpenSegmentMaxAge updates how long a traced segment is remembered to be
+// closed by Shutdown.  Segments that are never flushed, because they or one of
+// their subsegments are never closed, are forgotten after this age so they do
+// not stay in memory.  The default is ten minutes.
+func SetOpenSegmentMaxAge(maxAge time.Duration) {
+	openSegmentsMutex.Lock()
+	defer openSegmentsMutex.Unlock()
+	openSegmentMaxAge = maxAge
+}
+
+// Flusher represents an emitter that holds documents to be sent later, and
+// can wait for them to be sent.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// register records a traced segment as open so it can be closed by Shutdown.
+// It returns false if the SDK is shutting down.  Segments registered longer
+// ago than the maximum age are forgotten periodically.
+func register(s *Segment) bool {
+	openSegmentsMutex.Lock()
+	defer openSegmentsMutex.Unlock()
+
+	if shuttingDown {
+		return false
+	}
+
+	now := time.Now()
+	if now.Sub(openSegmentsPrune) >= openSegmentsPruneEvery {
+		openSegmentsPrune = now
+		for seg, registered := range openSegments {
+			if now.Sub(registered) > openSegmentMaxAge {
+				delete(openSegments, seg)
+			}
+		}
+	}
+
+	openSegments[s] = now
+
+	return true
+}
+
+// unregister removes a segment from the open segments.
+func unregister(s *Segment) {
+	openSegmentsMutex.Lock()
+	defer openSegmentsMutex.Unlock()
+	delete(openSegments, s)
+}
+
+// Shutdown stops tracing new segments, closes every open segment and
+// subsegment, and flushes the emitter.  Segments open for longer than the
+// maximum set with SetOpenSegmentMaxAge are not closed.  Segments and subsegments closed by
+// Shutdown are marked fault and annotated with "shutdown".  It returns when
+// everything has been sent or with the context error when the context is done
+// first.
+func Shutdown(ctx context.Context) error {
+	openSegmentsMutex.Lock()
+	shuttingDown = true
+	segments := make([]*Segment, 0, len(openSegments))
+	for seg := range openSegments {
+		segments = append(segments, seg)
+	}
+	openSegmentsMutex.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		for _, seg := range segments {
+			if closeErr := seg.forceClose(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+
+		if flusher, ok := GetEmitter().(Flusher); ok {
+			if flushErr := flusher.Flush(ctx); flushErr != nil && err == nil {
+				err = flushErr
+			}
+		}
+
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// forceClose closes the segment and all of its open subsegments, then sends
+// the segment if closing the subsegments did not.  Segments already flushed
+// are skipped.
+func (s *Segment) forceClose() error {
+	s.RLock()
+	open := s.EndTime == 0
+	flushed := s.flushed
+	subsegments := append([]*Subsegment{}, s.Subsegments...)
+	s.RUnlock()
+
+	// A segment flushed since the registry was read has already been sent.
+	if flushed {
+		return nil
+	}
+
+	if open {
+		s.AddFault()
+		s.AddAnnotation("shutdown", true)
+	}
+
+	// Closing the segment first defers sending it until the last open
+	// subsegment is closed.
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	for _, subseg := range subsegments {
+		subseg.forceClose()
+	}
+
+	return s.Flush()
+}
+
+// forceClose closes the subsegment and all of its open subsegments, deepest
+// first.
+func (s *Subsegment) forceClose() {
+	s.RLock()
+	subsegments := append([]*Subsegment{}, s.Subsegments...)
+	open := s.EndTime == 0
+	s.RUnlock()
+
+	for _, subseg := range subsegments {
+		subseg.forceClose()
+	}
+
+	if open {
+		s.AddAnnotation("shutdown", true)
+		s.Close(errShutdown, utils.FaultType)
+	}
+}
@@ -0,0 +1,202 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func resetShutdown() {
+	openSegmentsMutex.Lock()
+	defer openSegmentsMutex.Unlock()
+	shuttingDown = false
+	openSegments = map[*Segment]time.Time{}
+	openSegmentMaxAge = defaultOpenSegmentMaxAge
+	openSegmentsPrune = time.Time{}
+}
+
+func TestShutdown(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	resetShutdown()
+	defer resetShutdown()
+
+	memory := NewMemoryEmitter()
+	async := NewAsyncEmitter(memory, AsyncEmitterConfig{})
+	defer async.Close(context.Background())
+	SetEmitter(async)
+
+	seg := New("segment", nil)
+	seg.Traced = true
+	register(seg)
+
+	subseg := seg.AddNewSubsegment("open")
+	subseg.AddNewSubsegment("nested")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	documents := memory.Documents()
+	if len(documents) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(documents))
+	}
+
+	sent := &Segment{}
+	if err := json.Unmarshal(documents[0], sent); err != nil {
+		t.Fatal(err)
+	}
+
+	if !sent.Fault || sent.EndTime == 0 || sent.Annotations["shutdown"] != true {
+		t.Error("Segment should be closed and marked by shutdown")
+	}
+
+	if len(sent.Subsegments) != 1 || len(sent.Subsegments[0].Subsegments) != 1 {
+		t.Fatal("Segment should be sent with its subsegments")
+	}
+
+	for _, closed := range []*Subsegment{sent.Subsegments[0],
+		sent.Subsegments[0].Subsegments[0]} {
+		if !closed.Fault || closed.EndTime == 0 ||
+			closed.Annotations["shutdown"] != true {
+			t.Errorf("Subsegment %s should be closed and marked by shutdown",
+				closed.Name)
+		}
+	}
+
+	subseg.Close(nil, "")
+	seg.Close()
+	async.Flush(ctx)
+
+	if len(memory.Documents()) != 1 {
+		t.Error("Segments closed by shutdown should not be sent again")
+	}
+
+	if New("segment", nil).Traced {
+		t.Error("Segments created after shutdown should not be traced")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	resetShutdown()
+	defer resetShutdown()
+
+	blocking := &blockingEmitter{
+		release: make(chan struct{}),
+		memory:  NewMemoryEmitter(),
+	}
+	defer close(blocking.release)
+
+	async := NewAsyncEmitter(blocking, AsyncEmitterConfig{})
+	SetEmitter(async)
+	async.Send(New("segment", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+
+	if err := Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected error '%v', got '%v'", context.DeadlineExceeded, err)
+	}
+}
+
+// slowEmitter is an emitter that waits before encoding each document.
+type slowEmitter struct {
+	memory *MemoryEmitter
+}
+
+func (e *slowEmitter) Send(doc Document) error {
+	time.Sleep(time.Millisecond)
+	return e.memory.Send(doc)
+}
+
+func TestShutdownConcurrentFlush(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	resetShutdown()
+	defer resetShutdown()
+
+	memory := NewMemoryEmitter()
+	SetEmitter(&slowEmitter{memory: memory})
+
+	segments := []*Segment{}
+	for i := 0; i < 50; i++ {
+		seg := New("segment", nil)
+		seg.Traced = true
+		register(seg)
+		segments = append(segments, seg)
+	}
+
+	var wg sync.WaitGroup
+	for _, seg := range segments {
+		wg.Add(1)
+		go func(seg *Segment) {
+			defer wg.Done()
+			seg.Close()
+		}(seg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Closing segments should not deadlock with shutdown")
+	}
+
+	if len(memory.Documents()) != len(segments) {
+		t.Errorf("Expected %d documents, got %d", len(segments),
+			len(memory.Documents()))
+	}
+}
+
+func TestOpenSegmentMaxAge(t *testing.T) {
+	resetShutdown()
+	defer resetShutdown()
+
+	SetOpenSegmentMaxAge(time.Millisecond)
+
+	old := New("old", nil)
+	old.Traced = true
+	register(old)
+
+	time.Sleep(5 * time.Millisecond)
+
+	// Pruning runs at most every 30 seconds.
+	openSegmentsMutex.Lock()
+	openSegmentsPrune = time.Time{}
+	openSegmentsMutex.Unlock()
+
+	recent := New("recent", nil)
+	recent.Traced = true
+	register(recent)
+
+	openSegmentsMutex.Lock()
+	defer openSegmentsMutex.Unlock()
+
+	if _, ok := openSegments[old]; ok {
+		t.Error("Segments older than the maximum age should be forgotten")
+	}
+
+	if _, ok := openSegments[recent]; !ok {
+		t.Error("Recent segments should be remembered")
+	}
+}
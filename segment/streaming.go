@@ -0,0 +1,180 @@
+package segment
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+const (
+	defaultStreamingMaxSubsegments = 100
+)
+
+var (
+	streamingConfig      = StreamingConfig{MaxSubsegments: defaultStreamingMaxSubsegments}
+	streamingConfigMutex = &sync.RWMutex{}
+)
+
+// StreamingConfig represents the thresholds at which completed subsegments are
+// sent as independent subsegment documents instead of waiting for the segment
+// to be flushed.  A zero threshold is disabled.
+type StreamingConfig struct {
+	// MaxSubsegments is the number of subsegments a segment may hold before
+	// completed subsegments are streamed.
+	MaxSubsegments int
+	// MaxBytes is the encoded size a segment may reach before completed
+	// subsegments are streamed.  Checking the size requires encoding the
+	// segment each time a subsegment is closed.
+	MaxBytes int
+}
+
+// SetStreamingConfig updates the subsegment streaming thresholds.
+func SetStreamingConfig(config StreamingConfig) {
+	streamingConfigMutex.Lock()
+	defer streamingConfigMutex.Unlock()
+	streamingConfig = config
+}
+
+func getStreamingConfig() StreamingConfig {
+	streamingConfigMutex.RLock()
+	defer streamingConfigMutex.RUnlock()
+	return streamingConfig
+}
+
+// streamedSubsegmentsKey is the metadata key listing the IDs of subsegments
+// streamed from a segment or subsegment.
+const streamedSubsegmentsKey = "streamed_subsegments"
+
+// streamSubsegments sends completed subsegments as independent documents once
+// the segment exceeds a streaming threshold.  Closed subtrees are streamed at
+// any depth, so closed children of a subsegment that is still open are sent
+// too.  Streamed subsegments are replaced in their parent by their IDs in the
+// "streamed_subsegments" metadata; each document references its parent
+// through its trace ID and parent ID so X-Ray reassembles the trace.  Nothing
+// is streamed once the segment has been flushed.
+func (s *Segment) streamSubsegments() error {
+	config := getStreamingConfig()
+	if config.MaxSubsegments <= 0 && config.MaxBytes <= 0 {
+		return nil
+	}
+
+	s.Lock()
+
+	if !s.Traced || s.flushed || !s.exceedsStreamingConfig(config) {
+		s.Unlock()
+		return nil
+	}
+
+	streamed, remaining, ids := splitClosed(s.Subsegments, s.TraceID, s.ID)
+	s.Subsegments = remaining
+
+	s.Metadata = addStreamedReferences(s.Metadata, ids)
+
+	for _, subseg := range remaining {
+		streamed = append(streamed, subseg.streamClosed(s.TraceID)...)
+	}
+
+	s.Unlock()
+
+	var err error
+	for _, subseg := range streamed {
+		if sendErr := GetEmitter().Send(subseg); sendErr != nil && err == nil {
+			err = sendErr
+		}
+	}
+
+	return err
+}
+
+// streamClosed removes the closed subtrees below the open subsegment,
+// replacing them with references, and returns them ready to be sent.
+func (s *Subsegment) streamClosed(traceID string) []*Subsegment {
+	s.Lock()
+	defer s.Unlock()
+
+	streamed, remaining, ids := splitClosed(s.Subsegments, traceID, s.ID)
+	s.Subsegments = remaining
+
+	s.Metadata = addStreamedReferences(s.Metadata, ids)
+
+	for _, subseg := range remaining {
+		streamed = append(streamed, subseg.streamClosed(traceID)...)
+	}
+
+	return streamed
+}
+
+// splitClosed separates closed subtrees from open subsegments, preparing the
+// closed ones to be sent as subsegment documents of the parent.
+func splitClosed(
+	subsegments []*Subsegment,
+	traceID string,
+	parentID string,
+) ([]*Subsegment, []*Subsegment, []string) {
+
+	streamed := []*Subsegment{}
+	remaining := []*Subsegment{}
+	ids := []string{}
+
+	for _, subseg := range subsegments {
+		if !subseg.closed() {
+			remaining = append(remaining, subseg)
+			continue
+		}
+
+		subseg.Lock()
+		subseg.Type = "subsegment"
+		subseg.TraceID = traceID
+		subseg.ParentID = parentID
+		ids = append(ids, subseg.ID)
+		subseg.Unlock()
+
+		streamed = append(streamed, subseg)
+	}
+
+	return streamed, remaining, ids
+}
+
+// addStreamedReferences records the IDs of streamed subsegments in metadata,
+// creating the metadata if needed.
+func addStreamedReferences(m *metadata, ids []string) *metadata {
+	if len(ids) == 0 {
+		return m
+	}
+
+	if m == nil {
+		m = &metadata{Default: map[string]interface{}{}}
+	}
+
+	if m.Default == nil {
+		m.Default = map[string]interface{}{}
+	}
+
+	existing, _ := m.Default[streamedSubsegmentsKey].([]string)
+	m.Default[streamedSubsegmentsKey] = append(existing, ids...)
+
+	return m
+}
+
+// exceedsStreamingConfig returns whether the segment is over a streaming
+// threshold.  The segment must be locked by the caller.
+func (s *Segment) exceedsStreamingConfig(config StreamingConfig) bool {
+	if config.MaxSubsegments > 0 {
+		count := 0
+		for _, subseg := range s.Subsegments {
+			count += subseg.count()
+		}
+
+		if count > config.MaxSubsegments {
+			return true
+		}
+	}
+
+	if config.MaxBytes > 0 {
+		body, err := json.Marshal(s)
+		if err == nil && len(body) > config.MaxBytes {
+			return true
+		}
+	}
+
+	return false
+}
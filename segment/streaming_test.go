@@ -0,0 +1,226 @@
+package segment
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStreamSubsegments(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetStreamingConfig(getStreamingConfig())
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+	SetStreamingConfig(StreamingConfig{MaxSubsegments: 1})
+
+	seg := New("segment", nil)
+	seg.Traced = true
+
+	open := seg.AddNewSubsegment("open")
+	closed := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		subseg := seg.AddNewSubsegment("closed")
+		closed[subseg.ID] = true
+		subseg.AddNewSubsegment("nested").Close(nil, "")
+		subseg.Close(nil, "")
+	}
+
+	documents := memory.Documents()
+	if len(documents) == 0 {
+		t.Fatal("Closed subsegments should be streamed")
+	}
+
+	for _, document := range documents {
+		subseg := &Subsegment{}
+		if err := json.Unmarshal(document, subseg); err != nil {
+			t.Fatal(err)
+		}
+
+		if subseg.Type != "subsegment" {
+			t.Errorf("Streamed document type should be 'subsegment', got '%s'",
+				subseg.Type)
+		}
+
+		if subseg.TraceID != seg.TraceID {
+			t.Errorf("Streamed trace ID should be %s, got %s",
+				seg.TraceID, subseg.TraceID)
+		}
+
+		switch subseg.Name {
+		case "closed":
+			if subseg.ParentID != seg.ID {
+				t.Errorf("Streamed parent ID should be %s, got %s", seg.ID,
+					subseg.ParentID)
+			}
+		case "nested":
+			if !closed[subseg.ParentID] {
+				t.Errorf("Streamed nested subsegment should reference its "+
+					"parent subsegment, got %s", subseg.ParentID)
+			}
+		default:
+			t.Errorf("Unexpected streamed subsegment '%s'", subseg.Name)
+		}
+	}
+
+	if len(seg.Subsegments) != 1 || seg.Subsegments[0] != open {
+		t.Error("Streamed subsegments should be removed from the segment")
+	}
+
+	references, _ := seg.Metadata.Default[streamedSubsegmentsKey].([]string)
+	for _, id := range references {
+		if !closed[id] {
+			t.Errorf("Unexpected streamed subsegment reference %s", id)
+		}
+	}
+
+	if len(references) == 0 {
+		t.Error("Streamed subsegments should be replaced by references")
+	}
+
+	memory.Reset()
+	open.Close(nil, "")
+	seg.Close()
+
+	documents = memory.Documents()
+	if len(documents) == 0 {
+		t.Fatal("Segment should be flushed")
+	}
+
+	flushed := &Segment{}
+	if err := json.Unmarshal(documents[len(documents)-1], flushed); err != nil {
+		t.Fatal(err)
+	}
+
+	if flushed.ID != seg.ID {
+		t.Error("Last document should be the segment")
+	}
+}
+
+func TestStreamSubsegmentsDisabled(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetStreamingConfig(getStreamingConfig())
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+	SetStreamingConfig(StreamingConfig{})
+
+	seg := New("segment", nil)
+	seg.Traced = true
+
+	for i := 0; i < 200; i++ {
+		seg.AddNewSubsegment("subsegment").Close(nil, "")
+	}
+
+	if len(memory.Documents()) != 0 {
+		t.Error("Subsegments should not be streamed when streaming is disabled")
+	}
+
+	if len(seg.Subsegments) != 200 {
+		t.Errorf("Segment should have 200 subsegments, got %d", len(seg.Subsegments))
+	}
+}
+
+func TestStreamSubsegmentsMaxBytes(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetStreamingConfig(getStreamingConfig())
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+	SetStreamingConfig(StreamingConfig{MaxBytes: 2048})
+
+	seg := New("segment", nil)
+	seg.Traced = true
+
+	for i := 0; i < 50; i++ {
+		seg.AddNewSubsegment("subsegment").Close(nil, "")
+	}
+
+	if len(memory.Documents()) == 0 {
+		t.Error("Subsegments should be streamed when over the byte threshold")
+	}
+
+	if body, _ := seg.Bytes(); len(body) > 2048 {
+		t.Errorf("Segment should be under 2048 bytes, got %d", len(body))
+	}
+}
+
+func TestStreamSubsegmentsUnderOpenParent(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetStreamingConfig(getStreamingConfig())
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+	SetStreamingConfig(StreamingConfig{MaxSubsegments: 10})
+
+	seg := New("segment", nil)
+	seg.Traced = true
+
+	parent := seg.AddNewSubsegment("parent")
+	for i := 0; i < 100; i++ {
+		parent.AddNewSubsegment("call").Close(nil, "")
+	}
+
+	streamed := 0
+	for _, document := range memory.Documents() {
+		subseg := &Subsegment{}
+		if err := json.Unmarshal(document, subseg); err != nil {
+			t.Fatal(err)
+		}
+
+		if subseg.ParentID != parent.ID {
+			t.Errorf("Streamed parent ID should be %s, got %s", parent.ID,
+				subseg.ParentID)
+		}
+		streamed++
+	}
+
+	if streamed < 90 {
+		t.Errorf("Expected at least 90 streamed subsegments, got %d", streamed)
+	}
+
+	if count := parent.count(); count > 11 {
+		t.Errorf("Open parent should hold at most 10 subsegments, got %d",
+			count-1)
+	}
+
+	references, _ := parent.Metadata.Default[streamedSubsegmentsKey].([]string)
+	if len(references) != streamed {
+		t.Errorf("Expected %d streamed subsegment references, got %d",
+			streamed, len(references))
+	}
+}
+
+func TestStreamSubsegmentsAfterFlush(t *testing.T) {
+	originalEmitter := GetEmitter()
+	defer SetEmitter(originalEmitter)
+	defer SetStreamingConfig(getStreamingConfig())
+
+	memory := NewMemoryEmitter()
+	SetEmitter(memory)
+	SetStreamingConfig(StreamingConfig{MaxSubsegments: 10})
+
+	seg := New("segment", nil)
+	seg.Traced = true
+
+	subsegments := []*Subsegment{}
+	for i := 0; i < 20; i++ {
+		subsegments = append(subsegments, seg.AddNewSubsegment("subsegment"))
+	}
+
+	if err := seg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, subseg := range subsegments {
+		subseg.Close(nil, "")
+	}
+
+	if documents := memory.Documents(); len(documents) != 1 {
+		t.Errorf("Subsegments closed after the flush should not be streamed, "+
+			"got %d documents", len(documents))
+	}
+}
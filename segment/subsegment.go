@@ -2,6 +2,7 @@ package segment
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"github.com/goguardian/aws-xray-go/attributes"
 	"github.com/goguardian/aws-xray-go/utils"
@@ -12,9 +13,13 @@ import (
 type Subsegment struct {
 	Segment      *Segment               `json:"-"`
 	ID           string                 `json:"id"`
+	Type         string                 `json:"type,omitempty"`
+	TraceID      string                 `json:"trace_id,omitempty"`
+	ParentID     string                 `json:"parent_id,omitempty"`
 	Name         string                 `json:"name"`
 	StartTime    float64                `json:"start_time"`
-	EndTime      float64                `json:"end_time"`
+	EndTime      float64                `json:"end_time,omitempty"`
+	InProgress   bool                   `json:"in_progress,omitempty"`
 	PrecursorIDs []string               `json:"precursor_ids,omitempty"`
 	Namespace    string                 `json:"namespace,omitempty"`
 	Throttle     bool                   `json:"throttle"`
@@ -41,9 +46,10 @@ func NewSubsegment(name string) *Subsegment {
 	id := fmt.Sprintf("%x", idBytes)
 
 	return &Subsegment{
-		ID:        id,
-		Name:      name,
-		StartTime: startTime,
+		ID:         id,
+		Name:       name,
+		StartTime:  startTime,
+		InProgress: true,
 	}
 }
 
@@ -146,17 +152,21 @@ func (s *Subsegment) AddRemoteData(remote *attributes.Remote) {
 // AddSubsegment adds a subsegment to the slice of subsegment.
 func (s *Subsegment) AddSubsegment(subseg *Subsegment) {
 	s.Lock()
-	defer s.Unlock()
-
-	if subseg.EndTime == 0 {
-		subseg.Segment = s.Segment
+	segment := s.Segment
+	open := subseg.EndTime == 0
 
-		if s.Segment != nil {
-			s.Segment.Counter++
-		}
+	if open {
+		subseg.Segment = segment
 	}
 
 	s.Subsegments = append(s.Subsegments, subseg)
+	s.Unlock()
+
+	if open && segment != nil {
+		segment.Lock()
+		segment.Counter++
+		segment.Unlock()
+	}
 }
 
 // AddThrottle adds throttled flag into the subsegment.
@@ -166,6 +176,14 @@ func (s *Subsegment) AddThrottle() {
 	s.Throttle = true
 }
 
+// Bytes returns the subsegment as a JSON encoded byte slice
+func (s *Subsegment) Bytes() ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return json.Marshal(s)
+}
+
 // Close closes the current subsegment.  Additionally, it captures any exception
 // and sets the end time.
 func (s *Subsegment) Close(err error, errType string) {
@@ -173,6 +191,7 @@ func (s *Subsegment) Close(err error, errType string) {
 	if s.EndTime == 0 {
 		s.EndTime = utils.CurrentTimeSecond()
 	}
+	s.InProgress = false
 	s.Unlock()
 
 	if err != nil {
@@ -184,6 +203,40 @@ func (s *Subsegment) Close(err error, errType string) {
 	s.RUnlock()
 
 	if segment != nil {
-		s.Segment.DecrementCounter()
+		segment.streamSubsegments()
+		segment.DecrementCounter()
 	}
 }
+
+// closed returns whether the subsegment and all of its subsegments have been
+// closed.
+func (s *Subsegment) closed() bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.EndTime == 0 {
+		return false
+	}
+
+	for _, subseg := range s.Subsegments {
+		if !subseg.closed() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// count returns the number of subsegments in the subsegment tree, including
+// the subsegment itself.
+func (s *Subsegment) count() int {
+	s.RLock()
+	defer s.RUnlock()
+
+	count := 1
+	for _, subseg := range s.Subsegments {
+		count += subseg.count()
+	}
+
+	return count
+}
@@ -0,0 +1,233 @@
+package segment
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+const (
+	trimmedStackFrames = 5
+)
+
+// collapsedFlags are the flags of collapsed subsegments kept on their parents.
+var collapsedFlags = []string{"fault", "error", "throttle"}
+
+// TrimStep represents a way of reducing the size of a document that is over
+// the size limit.
+type TrimStep int
+
+const (
+	// TrimMetadata removes the metadata of all subsegments.
+	TrimMetadata TrimStep = iota
+	// TrimStacks truncates exception stacks to their first frames, recording
+	// the number of frames removed.
+	TrimStacks
+	// TrimLeafSubsegments repeatedly replaces subsegments without subsegments
+	// of their own with "collapsed_subsegments" and "collapsed_time"
+	// annotations on their parent.
+	TrimLeafSubsegments
+)
+
+var (
+	trimSteps      = []TrimStep{TrimMetadata, TrimStacks, TrimLeafSubsegments}
+	trimStepsMutex = &sync.RWMutex{}
+)
+
+// SetTrimStrategy updates the steps applied, in order, to documents over the
+// size limit until they fit.  Documents that do not fit after all steps are
+// dropped.  With no steps, oversize documents are always dropped.
+func SetTrimStrategy(steps ...TrimStep) {
+	trimStepsMutex.Lock()
+	defer trimStepsMutex.Unlock()
+	trimSteps = steps
+}
+
+func getTrimStrategy() []TrimStep {
+	trimStepsMutex.RLock()
+	defer trimStepsMutex.RUnlock()
+	return trimSteps
+}
+
+// trim applies the trim strategy to an encoded document until it is within
+// the limit.  Trimmed documents are annotated with "trimmed".  It returns
+// whether the document could be trimmed to fit.
+func trim(body []byte, limit int) ([]byte, bool) {
+	steps := getTrimStrategy()
+	if len(steps) == 0 {
+		return body, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	doc := map[string]interface{}{}
+	if err := decoder.Decode(&doc); err != nil {
+		return body, false
+	}
+
+	annotations, _ := doc["annotations"].(map[string]interface{})
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+		doc["annotations"] = annotations
+	}
+	annotations["trimmed"] = true
+
+	for _, step := range steps {
+		switch step {
+		case TrimMetadata:
+			walk(doc, func(node map[string]interface{}, root bool) {
+				if !root {
+					delete(node, "metadata")
+				}
+			})
+		case TrimStacks:
+			walk(doc, truncateStacks)
+		case TrimLeafSubsegments:
+			for hasSubsegments(doc) {
+				if trimmed, ok := fits(doc, limit); ok {
+					return trimmed, true
+				}
+
+				collapseLeaves(doc)
+			}
+		}
+
+		if trimmed, ok := fits(doc, limit); ok {
+			return trimmed, true
+		}
+	}
+
+	return body, false
+}
+
+// fits encodes the document and returns whether it is within the limit.
+func fits(doc map[string]interface{}, limit int) ([]byte, bool) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+
+	return body, len(body) <= limit
+}
+
+// walk calls fn for the document and every subsegment in it.
+func walk(node map[string]interface{}, fn func(map[string]interface{}, bool)) {
+	walkNode(node, fn, true)
+}
+
+func walkNode(
+	node map[string]interface{},
+	fn func(map[string]interface{}, bool),
+	root bool,
+) {
+
+	fn(node, root)
+
+	subsegments, _ := node["subsegments"].([]interface{})
+	for _, subsegment := range subsegments {
+		if child, ok := subsegment.(map[string]interface{}); ok {
+			walkNode(child, fn, false)
+		}
+	}
+}
+
+// truncateStacks truncates the stacks of the exceptions of a node.
+func truncateStacks(node map[string]interface{}, root bool) {
+	cause, _ := node["cause"].(map[string]interface{})
+	exceptions, _ := cause["exceptions"].([]interface{})
+
+	for _, e := range exceptions {
+		exception, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		stack, _ := exception["stack"].([]interface{})
+		if len(stack) > trimmedStackFrames {
+			exception["stack"] = stack[:trimmedStackFrames]
+			exception["truncated"] = len(stack) - trimmedStackFrames
+		}
+	}
+}
+
+func hasSubsegments(node map[string]interface{}) bool {
+	subsegments, _ := node["subsegments"].([]interface{})
+	return len(subsegments) > 0
+}
+
+// collapseLeaves replaces the subsegments without subsegments of their own in
+// the document with summary annotations on their parents.  The collapsed time
+// only includes closed subsegments.  The fault, error and throttle flags of
+// collapsed subsegments are set on their parents, so the trace still shows
+// where a failure happened.
+func collapseLeaves(node map[string]interface{}) {
+	subsegments, _ := node["subsegments"].([]interface{})
+	if len(subsegments) == 0 {
+		return
+	}
+
+	remaining := []interface{}{}
+	collapsed := 0
+	collapsedTime := 0.0
+
+	for _, subsegment := range subsegments {
+		child, ok := subsegment.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if hasSubsegments(child) {
+			collapseLeaves(child)
+			remaining = append(remaining, child)
+			continue
+		}
+
+		collapsed++
+		for _, flag := range collapsedFlags {
+			if child[flag] == true {
+				node[flag] = true
+			}
+		}
+
+		if endTime := number(child["end_time"]); endTime > 0 {
+			collapsedTime += endTime - number(child["start_time"])
+		}
+	}
+
+	if collapsed == 0 {
+		return
+	}
+
+	annotations, _ := node["annotations"].(map[string]interface{})
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+		node["annotations"] = annotations
+	}
+
+	annotations["collapsed_subsegments"] =
+		int(number(annotations["collapsed_subsegments"])) + collapsed
+	annotations["collapsed_time"] =
+		number(annotations["collapsed_time"]) + collapsedTime
+
+	if len(remaining) == 0 {
+		delete(node, "subsegments")
+	} else {
+		node["subsegments"] = remaining
+	}
+}
+
+// number returns the float value of a decoded JSON number, or zero.
+func number(value interface{}) float64 {
+	switch n := value.(type) {
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	}
+
+	return 0
+}
@@ -0,0 +1,161 @@
+package segment
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/goguardian/aws-xray-go/attributes"
+	"github.com/goguardian/aws-xray-go/utils"
+	"strings"
+	"testing"
+)
+
+func TestTrim(t *testing.T) {
+	defer SetTrimStrategy(getTrimStrategy()...)
+
+	seg := New("segment", nil)
+	seg.AddError(errors.New("error"))
+	stack := make([]attributes.LocalExceptionStack, 20)
+	for i := range stack {
+		stack[i] = attributes.LocalExceptionStack{Label: strings.Repeat("f", 100)}
+	}
+	seg.Cause.Exceptions[0].Stack = stack
+
+	parent := seg.AddNewSubsegment("parent")
+	parent.AddMetadata("large", strings.Repeat("m", 2000))
+	for i := 0; i < 20; i++ {
+		child := parent.AddNewSubsegment("child")
+		switch i {
+		case 0:
+			child.Close(errors.New("fault"), utils.FaultType)
+		case 1:
+			child.Close(errors.New("error"), utils.ErrorType)
+		case 2:
+			child.AddThrottle()
+			child.Close(nil, "")
+		default:
+			child.Close(nil, "")
+		}
+	}
+
+	body, _ := seg.Bytes()
+
+	tests := []struct {
+		limit           int
+		steps           []TrimStep
+		expectFit       bool
+		expectMetadata  bool
+		expectStack     int
+		expectCollapsed bool
+	}{
+		{
+			limit:          len(body),
+			steps:          []TrimStep{TrimMetadata, TrimStacks, TrimLeafSubsegments},
+			expectFit:      true,
+			expectMetadata: false,
+			expectStack:    20,
+		},
+		{
+			limit:       len(body) - 2500,
+			steps:       []TrimStep{TrimMetadata, TrimStacks, TrimLeafSubsegments},
+			expectFit:   true,
+			expectStack: trimmedStackFrames,
+		},
+		{
+			limit:           len(body) - 5000,
+			steps:           []TrimStep{TrimMetadata, TrimStacks, TrimLeafSubsegments},
+			expectFit:       true,
+			expectStack:     trimmedStackFrames,
+			expectCollapsed: true,
+		},
+		{
+			limit:     len(body) - 2500,
+			steps:     []TrimStep{TrimMetadata},
+			expectFit: false,
+		},
+		{
+			limit:     len(body) - 1,
+			steps:     []TrimStep{},
+			expectFit: false,
+		},
+		{
+			limit:     100,
+			steps:     []TrimStep{TrimMetadata, TrimStacks, TrimLeafSubsegments},
+			expectFit: false,
+		},
+	}
+
+	for i, test := range tests {
+		SetTrimStrategy(test.steps...)
+
+		trimmed, ok := trim(body, test.limit)
+		if ok != test.expectFit {
+			t.Errorf("Test %d: expected fit %t, got %t", i, test.expectFit, ok)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		if len(trimmed) > test.limit {
+			t.Errorf("Test %d: trimmed document is %d bytes, limit %d",
+				i, len(trimmed), test.limit)
+		}
+
+		decoded := &Segment{}
+		if err := json.Unmarshal(trimmed, decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.Annotations["trimmed"] != true {
+			t.Errorf("Test %d: trimmed document should be annotated", i)
+		}
+
+		if len(decoded.Subsegments) != 1 {
+			t.Fatalf("Test %d: parent subsegment should be kept", i)
+		}
+		decodedParent := decoded.Subsegments[0]
+
+		if (decodedParent.Metadata != nil) != test.expectMetadata {
+			t.Errorf("Test %d: expected metadata %t", i, test.expectMetadata)
+		}
+
+		if len(decoded.Cause.Exceptions[0].Stack) != test.expectStack {
+			t.Errorf("Test %d: expected %d stack frames, got %d", i,
+				test.expectStack, len(decoded.Cause.Exceptions[0].Stack))
+		}
+
+		collapsed := decodedParent.Annotations["collapsed_subsegments"]
+		if test.expectCollapsed {
+			if collapsed != float64(20) || len(decodedParent.Subsegments) != 0 {
+				t.Errorf("Test %d: expected 20 collapsed subsegments, got %v",
+					i, collapsed)
+			}
+
+			if !decodedParent.Fault || !decodedParent.Error ||
+				!decodedParent.Throttle {
+				t.Errorf("Test %d: collapsed flags should be set on the parent",
+					i)
+			}
+		} else if len(decodedParent.Subsegments) != 20 {
+			t.Errorf("Test %d: subsegments should not be collapsed", i)
+		}
+	}
+}
+
+func TestEncodeTrimsOversize(t *testing.T) {
+	seg := New("segment", nil)
+	for i := 0; i < 1000; i++ {
+		seg.AddNewSubsegment("subsegment").Close(nil, "")
+	}
+
+	body, err := encode(seg, maxBodySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body) > maxBodySize {
+		t.Errorf("Encoded segment should be trimmed to %d bytes, got %d",
+			maxBodySize, len(body))
+	}
+}
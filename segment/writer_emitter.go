@@ -0,0 +1,40 @@
+package segment
+
+import (
+	"io"
+	"sync"
+)
+
+// WriterEmitter writes every document it is sent to an io.Writer as a single
+// line of JSON.
+type WriterEmitter struct {
+	writer io.Writer
+
+	sync.Mutex
+}
+
+// NewWriterEmitter creates a new emitter writing to w.
+func NewWriterEmitter(w io.Writer) *WriterEmitter {
+	return &WriterEmitter{writer: w}
+}
+
+// Send encodes the document and writes it followed by a newline.
+func (e *WriterEmitter) Send(doc Document) error {
+	body, err := encode(doc, 0)
+	if err != nil {
+		return err
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	line := append(body, protocolDelimiter...)
+	if _, err = e.writer.Write(line); err != nil {
+		recordWriterError()
+		return err
+	}
+
+	recordWritten(len(line))
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package segment
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriterEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewWriterEmitter(&buf)
+
+	for i := 0; i < 2; i++ {
+		if err := emitter.Send(New("segment", nil)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Writer emitter should write 2 lines, got %d", len(lines))
+	}
+
+	for _, line := range lines {
+		decoded := &Segment{}
+		if err := json.Unmarshal([]byte(line), decoded); err != nil {
+			t.Error(err)
+		}
+
+		if decoded.Name != "segment" {
+			t.Errorf("Document name should be 'segment', got '%s'", decoded.Name)
+		}
+	}
+}
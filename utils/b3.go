@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// B3TraceIDHeader represents the B3 trace ID header key.
+	B3TraceIDHeader = "X-B3-TraceId"
+	// B3SpanIDHeader represents the B3 span ID header key.
+	B3SpanIDHeader = "X-B3-SpanId"
+	// B3SampledHeader represents the B3 sampling decision header key.
+	B3SampledHeader = "X-B3-Sampled"
+	// B3FlagsHeader represents the B3 debug flag header key.
+	B3FlagsHeader = "X-B3-Flags"
+	// B3Header represents the single B3 header key.
+	B3Header = "b3"
+)
+
+// B3Propagator propagates trace headers in Zipkin B3 headers, either the
+// X-B3-* headers or the single b3 header.  Both forms are extracted, with the
+// single header taking precedence.  The B3 span ID is used as the parent ID,
+// and 64-bit B3 trace IDs are padded with zeros to 128 bits.
+type B3Propagator struct {
+	// SingleHeader injects the single b3 header instead of the X-B3-*
+	// headers.
+	SingleHeader bool
+}
+
+// Extract parses the b3 header, or the X-B3-* headers.  A b3 header with only
+// a sampling state returns a trace header without IDs, carrying the upstream
+// sampling decision.
+func (B3Propagator) Extract(carrier Carrier) (*TraceHeader, error) {
+	if value := strings.TrimSpace(carrier.Get(B3Header)); value != "" {
+		parts := strings.Split(value, "-")
+		if len(parts) == 1 {
+			return b3SamplingOnly(value)
+		}
+
+		if len(parts) > 4 {
+			return nil, fmt.Errorf("invalid %s header %q", B3Header, value)
+		}
+
+		sampled := ""
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+
+		return b3TraceHeader(parts[0], parts[1], sampled, "")
+	}
+
+	traceID := strings.TrimSpace(carrier.Get(B3TraceIDHeader))
+	if traceID == "" {
+		return nil, fmt.Errorf("missing %s header", B3TraceIDHeader)
+	}
+
+	return b3TraceHeader(traceID,
+		strings.TrimSpace(carrier.Get(B3SpanIDHeader)),
+		strings.TrimSpace(carrier.Get(B3SampledHeader)),
+		strings.TrimSpace(carrier.Get(B3FlagsHeader)))
+}
+
+// Inject sets the b3 header or the X-B3-* headers.  Headers without a valid
+// trace ID and parent ID are not injected, and the sampling decision is left
+// out when it has not been made.
+func (p B3Propagator) Inject(carrier Carrier, header *TraceHeader) {
+	if !ValidTraceID(header.Root) || !ValidParentID(header.Parent) {
+		return
+	}
+
+	traceID := traceIDToHex(header.Root)
+	sampled := header.Sampled
+	if sampled != "0" && sampled != "1" {
+		sampled = ""
+	}
+
+	if p.SingleHeader {
+		value := traceID + "-" + header.Parent
+		if sampled != "" {
+			value += "-" + sampled
+		}
+
+		carrier.Set(B3Header, value)
+		return
+	}
+
+	carrier.Set(B3TraceIDHeader, traceID)
+	carrier.Set(B3SpanIDHeader, header.Parent)
+	if sampled != "" {
+		carrier.Set(B3SampledHeader, sampled)
+	}
+}
+
+// b3TraceHeader returns the trace header for B3 IDs and sampling state.
+func b3TraceHeader(traceID, spanID, sampled, flags string) (*TraceHeader, error) {
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+
+	if len(traceID) != 32 || !isHex(traceID) || isZero(traceID) {
+		return nil, fmt.Errorf("invalid B3 trace ID %q", traceID)
+	}
+
+	if !ValidParentID(spanID) || isZero(spanID) {
+		return nil, fmt.Errorf("invalid B3 span ID %q", spanID)
+	}
+
+	header := &TraceHeader{Root: traceIDFromHex(traceID), Parent: spanID}
+
+	switch {
+	case flags == "1", sampled == "d", sampled == "1", sampled == "true":
+		header.Sampled = "1"
+	case sampled == "0", sampled == "false":
+		header.Sampled = "0"
+	}
+
+	return header, nil
+}
+
+// b3SamplingOnly returns the trace header for a b3 header carrying only a
+// sampling state.
+func b3SamplingOnly(value string) (*TraceHeader, error) {
+	switch value {
+	case "1", "d":
+		return &TraceHeader{Sampled: "1"}, nil
+	case "0":
+		return &TraceHeader{Sampled: "0"}, nil
+	}
+
+	return nil, fmt.Errorf("invalid %s header %q", B3Header, value)
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const testB3TraceID = "5759e988bd862e3fe1be46a994272793"
+
+func TestB3PropagatorExtract(t *testing.T) {
+	tests := []struct {
+		headers map[string]string
+		expect  *TraceHeader
+	}{
+		{
+			headers: map[string]string{
+				B3TraceIDHeader: testB3TraceID,
+				B3SpanIDHeader:  testParentID,
+				B3SampledHeader: "1",
+			},
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID,
+				Sampled: "1"},
+		},
+		{
+			headers: map[string]string{
+				B3TraceIDHeader: "bd862e3fe1be46a9",
+				B3SpanIDHeader:  testParentID,
+				B3SampledHeader: "0",
+			},
+			expect: &TraceHeader{Root: "1-00000000-00000000bd862e3fe1be46a9",
+				Parent: testParentID, Sampled: "0"},
+		},
+		{
+			headers: map[string]string{
+				B3TraceIDHeader: testB3TraceID,
+				B3SpanIDHeader:  testParentID,
+				B3FlagsHeader:   "1",
+			},
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID,
+				Sampled: "1"},
+		},
+		{
+			headers: map[string]string{
+				B3TraceIDHeader: testB3TraceID,
+				B3SpanIDHeader:  testParentID,
+			},
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID},
+		},
+		{
+			headers: map[string]string{
+				B3Header: testB3TraceID + "-" + testParentID + "-d-" +
+					"e457b5a2e4d86bd1",
+				B3TraceIDHeader: "bd862e3fe1be46a9",
+			},
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID,
+				Sampled: "1"},
+		},
+		{
+			headers: map[string]string{
+				B3Header: testB3TraceID + "-" + testParentID,
+			},
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID},
+		},
+		{
+			headers: map[string]string{B3Header: "0"},
+			expect:  &TraceHeader{Sampled: "0"},
+		},
+		{
+			headers: map[string]string{B3Header: "1"},
+			expect:  &TraceHeader{Sampled: "1"},
+		},
+		{
+			headers: map[string]string{B3Header: "d"},
+			expect:  &TraceHeader{Sampled: "1"},
+		},
+		{headers: map[string]string{}},
+		{headers: map[string]string{B3Header: "x"}},
+		{headers: map[string]string{B3Header: testB3TraceID + "-123"}},
+		{headers: map[string]string{B3TraceIDHeader: testB3TraceID}},
+		{headers: map[string]string{B3TraceIDHeader: "123",
+			B3SpanIDHeader: testParentID}},
+	}
+
+	for _, test := range tests {
+		carrier := HTTPHeaderCarrier(http.Header{})
+		for key, value := range test.headers {
+			carrier.Set(key, value)
+		}
+
+		header, err := B3Propagator{}.Extract(carrier)
+		if test.expect == nil {
+			if err == nil {
+				t.Errorf("Expected error extracting %v", test.headers)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error extracting %v: %s", test.headers, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(header, test.expect) {
+			t.Errorf("Expected header %+v, got %+v", test.expect, header)
+		}
+	}
+}
+
+func TestB3PropagatorInject(t *testing.T) {
+	header := &TraceHeader{Root: testTraceID, Parent: testParentID, Sampled: "1"}
+
+	carrier := HTTPHeaderCarrier(http.Header{})
+	B3Propagator{}.Inject(carrier, header)
+
+	expected := map[string]string{
+		B3TraceIDHeader: testB3TraceID,
+		B3SpanIDHeader:  testParentID,
+		B3SampledHeader: "1",
+	}
+	for key, value := range expected {
+		if carrier.Get(key) != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, carrier.Get(key))
+		}
+	}
+
+	md := MetadataCarrier{}
+	B3Propagator{SingleHeader: true}.Inject(md, header)
+
+	single := testB3TraceID + "-" + testParentID + "-1"
+	if value := md.Get(B3Header); value != single {
+		t.Errorf("Expected %s '%s', got '%s'", B3Header, single, value)
+	}
+
+	if value := md.Get(B3TraceIDHeader); value != "" {
+		t.Errorf("Single header should not inject %s, got '%s'",
+			B3TraceIDHeader, value)
+	}
+}
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"net/http"
-	"strings"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/metadata"
@@ -17,13 +16,42 @@ const (
 	mdSegmentKey = "xray-segment"
 )
 
+type contextKey int
+
+const traceHeaderContextKey contextKey = iota
+
+// NewTraceHeaderContext returns a copy of the context carrying an incoming
+// trace header.
+func NewTraceHeaderContext(
+	ctx context.Context,
+	header *TraceHeader,
+) context.Context {
+
+	return context.WithValue(ctx, traceHeaderContextKey, header)
+}
+
+// TraceHeaderFromContext returns the incoming trace header carried by the
+// context, if any.
+func TraceHeaderFromContext(ctx context.Context) (*TraceHeader, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	header, ok := ctx.Value(traceHeaderContextKey).(*TraceHeader)
+	return header, ok && header != nil
+}
+
 // GetIDsFromContext returns the root ID, parent ID, and whether the request
-// was sampled based on context metadata.
+// was sampled based on the context trace header or metadata.
 func GetIDsFromContext(ctx context.Context) (rootID, parentID, sampled string) {
 	if ctx == nil {
 		return
 	}
 
+	if header, ok := TraceHeaderFromContext(ctx); ok {
+		return header.Root, header.Parent, header.Sampled
+	}
+
 	data, ok := metadata.FromContext(ctx)
 	if !ok {
 		return
@@ -47,52 +75,14 @@ func GetIDsFromContext(ctx context.Context) (rootID, parentID, sampled string) {
 	return
 }
 
-// ContextFromHeaders parses the root ID, parent ID, and whether the
-// request was sampled based on the HTTP request X-Ray header and returns a
-// new context containing the metadata.
+// ContextFromHeaders extracts the trace header of an HTTP request with the
+// configured propagators and returns a new context carrying the trace header.
+// The request context is returned when there is no valid trace header.
 func ContextFromHeaders(r *http.Request) context.Context {
-	header, rootID, parentID, sampled := "", "", "", ""
-
-	headers, ok := r.Header[XRayHeader]
-	if ok && len(headers) > 0 {
-		header = headers[0]
-	}
-
-	if header == "" {
-		headers, ok = r.Header[strings.ToLower(XRayHeader)]
-		if ok && len(headers) > 0 {
-			header = headers[0]
-		}
-	}
-
-	if header == "" {
+	traceHeader, err := ExtractTraceHeader(HTTPHeaderCarrier(r.Header))
+	if err != nil {
 		return r.Context()
 	}
 
-	pairs := strings.Split(strings.Replace(header, " ", "", -1), ";")
-	for _, pair := range pairs {
-		set := strings.Split(pair, "=")
-		if len(set) == 2 {
-			if set[0] == "Root" {
-				rootID = set[1]
-			} else if set[0] == "Parent" {
-				parentID = set[1]
-			} else if set[0] == "Sampled" {
-				sampled = set[1]
-			}
-		}
-	}
-
-	newMD := metadata.New(map[string]string{
-		mdRootKey:    rootID,
-		mdParentKey:  parentID,
-		mdSampledKey: sampled,
-	})
-
-	oldMD, found := metadata.FromContext(r.Context())
-	if found {
-		newMD = metadata.Join(newMD, oldMD)
-	}
-
-	return metadata.NewContext(r.Context(), newMD)
+	return NewTraceHeaderContext(r.Context(), traceHeader)
 }
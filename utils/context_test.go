@@ -30,23 +30,34 @@ func TestGetIDsFromContext(t *testing.T) {
 		{
 			request: &http.Request{
 				Header: http.Header{
-					XRayHeader: []string{"Root=123; Parent=456"},
+					XRayHeader: []string{"Root=" + testTraceID + "; Parent=" + testParentID},
 				},
 			},
-			expectRootID:   "123",
-			expectParentID: "456",
+			expectRootID:   testTraceID,
+			expectParentID: testParentID,
 			expectSampled:  "",
 		},
 		{
 			request: &http.Request{
 				Header: http.Header{
-					strings.ToLower(XRayHeader): []string{"Root=123; Parent=456; Sampled=1"},
+					strings.ToLower(XRayHeader): []string{"Root=" + testTraceID +
+						"; Parent=" + testParentID + "; Sampled=1"},
 				},
 			},
-			expectRootID:   "123",
-			expectParentID: "456",
+			expectRootID:   testTraceID,
+			expectParentID: testParentID,
 			expectSampled:  "1",
 		},
+		{
+			request: &http.Request{
+				Header: http.Header{
+					XRayHeader: []string{"Root=123; Parent=456; Sampled=1"},
+				},
+			},
+			expectRootID:   "",
+			expectParentID: "",
+			expectSampled:  "",
+		},
 		{
 			ctx:            context.TODO(),
 			expectRootID:   "",
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DaemonAddressEnv represents the environment variable holding the X-Ray
+	// daemon address used by the AWS X-Ray SDKs.
+	DaemonAddressEnv = "AWS_XRAY_DAEMON_ADDRESS"
+	// DefaultDaemonAddress represents the default X-Ray daemon address.
+	DefaultDaemonAddress = "127.0.0.1:2000"
+	// UnixgramScheme prefixes daemon addresses that are the path of a Unix
+	// datagram socket.
+	UnixgramScheme = "unixgram://"
+)
+
+// DaemonAddress represents the addresses of the X-Ray daemon.  Segments are
+// sent to the UDP address, which may also be a "unixgram://path" Unix datagram
+// socket, and the daemon's HTTP proxy to the X-Ray API is served on the TCP
+// address.
+type DaemonAddress struct {
+	UDP string
+	TCP string
+}
+
+// GetDaemonAddress returns the daemon address configured in the environment.
+// AWS_XRAY_DAEMON_ADDRESS takes precedence over XRAY_DAEMON_HOST and
+// XRAY_DAEMON_PORT.
+func GetDaemonAddress() (*DaemonAddress, error) {
+	if address := GetenvOrDefault(DaemonAddressEnv, ""); address != "" {
+		return ParseDaemonAddress(address)
+	}
+
+	host := GetenvOrDefault("XRAY_DAEMON_HOST", "127.0.0.1")
+	port := GetenvOrDefault("XRAY_DAEMON_PORT", "2000")
+
+	return ParseDaemonAddress(net.JoinHostPort(host, port))
+}
+
+// ParseDaemonAddress parses a daemon address in either the "host:port" form,
+// used for both UDP and TCP, or the "tcp:host:port udp:host:port" form, with
+// the two addresses in any order.  The UDP address may be given as
+// "unixgram://path" instead, in which case a lone Unix socket address uses the
+// default TCP address.
+func ParseDaemonAddress(address string) (*DaemonAddress, error) {
+	fields := strings.Fields(address)
+
+	switch len(fields) {
+	case 1:
+		if strings.HasPrefix(fields[0], UnixgramScheme) {
+			if err := validateUnixgram(fields[0]); err != nil {
+				return nil, fmt.Errorf("invalid daemon address %q: %s",
+					address, err.Error())
+			}
+
+			return &DaemonAddress{UDP: fields[0], TCP: DefaultDaemonAddress}, nil
+		}
+
+		if err := validateHostPort(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid daemon address %q: %s",
+				address, err.Error())
+		}
+
+		return &DaemonAddress{UDP: fields[0], TCP: fields[0]}, nil
+	case 2:
+	default:
+		return nil, fmt.Errorf("invalid daemon address %q: expected "+
+			"\"host:port\" or \"tcp:host:port udp:host:port\"", address)
+	}
+
+	daemonAddress := &DaemonAddress{}
+	for _, field := range fields {
+		parts := strings.SplitN(field, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid daemon address %q: %q should be "+
+				"prefixed with \"tcp:\" or \"udp:\"", address, field)
+		}
+
+		validate := validateHostPort
+		if parts[0] == "udp" && strings.HasPrefix(parts[1], UnixgramScheme) {
+			validate = validateUnixgram
+		}
+
+		if err := validate(parts[1]); err != nil {
+			return nil, fmt.Errorf("invalid daemon address %q: %s",
+				address, err.Error())
+		}
+
+		switch parts[0] {
+		case "tcp":
+			if daemonAddress.TCP != "" {
+				return nil, fmt.Errorf("invalid daemon address %q: duplicate "+
+					"TCP address", address)
+			}
+			daemonAddress.TCP = parts[1]
+		case "udp":
+			if daemonAddress.UDP != "" {
+				return nil, fmt.Errorf("invalid daemon address %q: duplicate "+
+					"UDP address", address)
+			}
+			daemonAddress.UDP = parts[1]
+		default:
+			return nil, fmt.Errorf("invalid daemon address %q: unknown "+
+				"protocol %q", address, parts[0])
+		}
+	}
+
+	return daemonAddress, nil
+}
+
+// validateHostPort returns an error if the address is not a host and valid
+// port number.
+func validateHostPort(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if host == "" {
+		return fmt.Errorf("missing host in %q", address)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return fmt.Errorf("invalid port %q in %q", port, address)
+	}
+
+	return nil
+}
+
+// validateUnixgram returns an error if the address is not a Unix datagram
+// socket address with a path.
+func validateUnixgram(address string) error {
+	if strings.TrimPrefix(address, UnixgramScheme) == "" {
+		return fmt.Errorf("missing socket path in %q", address)
+	}
+
+	return nil
+}
+
+// DaemonNetwork returns the network and dial address of a daemon UDP address,
+// which is either "udp" and a host and port, or "unixgram" and a socket path.
+func DaemonNetwork(address string) (string, string) {
+	if strings.HasPrefix(address, UnixgramScheme) {
+		return "unixgram", strings.TrimPrefix(address, UnixgramScheme)
+	}
+
+	return "udp", address
+}
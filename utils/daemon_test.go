@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseDaemonAddress(t *testing.T) {
+	tests := []struct {
+		address     string
+		expectUDP   string
+		expectTCP   string
+		expectError bool
+	}{
+		{
+			address:   "127.0.0.1:2000",
+			expectUDP: "127.0.0.1:2000",
+			expectTCP: "127.0.0.1:2000",
+		},
+		{
+			address:   "xray-daemon:3000",
+			expectUDP: "xray-daemon:3000",
+			expectTCP: "xray-daemon:3000",
+		},
+		{
+			address:   "tcp:127.0.0.1:2000 udp:127.0.0.2:2001",
+			expectUDP: "127.0.0.2:2001",
+			expectTCP: "127.0.0.1:2000",
+		},
+		{
+			address:   "udp:[::1]:2001 tcp:[::1]:2000",
+			expectUDP: "[::1]:2001",
+			expectTCP: "[::1]:2000",
+		},
+		{
+			address:   "unixgram:///var/run/xray.sock",
+			expectUDP: "unixgram:///var/run/xray.sock",
+			expectTCP: DefaultDaemonAddress,
+		},
+		{
+			address:   "tcp:xray-daemon:2000 udp:unixgram:///var/run/xray.sock",
+			expectUDP: "unixgram:///var/run/xray.sock",
+			expectTCP: "xray-daemon:2000",
+		},
+		{address: "", expectError: true},
+		{address: "unixgram://", expectError: true},
+		{address: "tcp:unixgram:///var/run/xray.sock udp:127.0.0.1:2000",
+			expectError: true},
+		{address: "127.0.0.1", expectError: true},
+		{address: ":2000", expectError: true},
+		{address: "127.0.0.1:port", expectError: true},
+		{address: "127.0.0.1:70000", expectError: true},
+		{address: "tcp:127.0.0.1:2000", expectError: true},
+		{address: "tcp:127.0.0.1:2000 tcp:127.0.0.1:2001", expectError: true},
+		{address: "udp:127.0.0.1:2000 udp:127.0.0.1:2001", expectError: true},
+		{address: "tcp:127.0.0.1:2000 http:127.0.0.1:2001", expectError: true},
+		{address: "tcp:127.0.0.1:2000 127.0.0.1", expectError: true},
+		{address: "a:1 b:2 c:3", expectError: true},
+	}
+
+	for _, test := range tests {
+		address, err := ParseDaemonAddress(test.address)
+		if test.expectError {
+			if err == nil {
+				t.Errorf("Expected error parsing '%s'", test.address)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error parsing '%s': %s", test.address, err)
+			continue
+		}
+
+		if address.UDP != test.expectUDP {
+			t.Errorf("Expected UDP address '%s', got '%s'", test.expectUDP, address.UDP)
+		}
+
+		if address.TCP != test.expectTCP {
+			t.Errorf("Expected TCP address '%s', got '%s'", test.expectTCP, address.TCP)
+		}
+	}
+}
+
+func TestGetDaemonAddress(t *testing.T) {
+	defer os.Unsetenv(DaemonAddressEnv)
+
+	os.Unsetenv(DaemonAddressEnv)
+	os.Setenv("XRAY_DAEMON_HOST", "localhost")
+	os.Setenv("XRAY_DAEMON_PORT", "3000")
+	defer os.Unsetenv("XRAY_DAEMON_HOST")
+	defer os.Unsetenv("XRAY_DAEMON_PORT")
+
+	address, err := GetDaemonAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if address.UDP != "localhost:3000" {
+		t.Errorf("Expected UDP address 'localhost:3000', got '%s'", address.UDP)
+	}
+
+	os.Setenv(DaemonAddressEnv, "tcp:127.0.0.1:2000 udp:127.0.0.1:2001")
+
+	address, err = GetDaemonAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if address.UDP != "127.0.0.1:2001" || address.TCP != "127.0.0.1:2000" {
+		t.Errorf("Unexpected daemon address %+v", address)
+	}
+
+	os.Setenv(DaemonAddressEnv, "invalid")
+
+	if _, err := GetDaemonAddress(); err == nil {
+		t.Error("Expected error for invalid daemon address")
+	}
+}
+
+func TestDaemonNetwork(t *testing.T) {
+	tests := []struct {
+		address       string
+		expectNetwork string
+		expectAddress string
+	}{
+		{"127.0.0.1:2000", "udp", "127.0.0.1:2000"},
+		{"unixgram:///var/run/xray.sock", "unixgram", "/var/run/xray.sock"},
+	}
+
+	for _, test := range tests {
+		network, address := DaemonNetwork(test.address)
+
+		if network != test.expectNetwork {
+			t.Errorf("Expected network '%s', got '%s'", test.expectNetwork, network)
+		}
+
+		if address != test.expectAddress {
+			t.Errorf("Expected address '%s', got '%s'", test.expectAddress, address)
+		}
+	}
+}
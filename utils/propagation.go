@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// TraceParentHeader represents the W3C Trace Context traceparent header
+	// key.
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader represents the W3C Trace Context tracestate header key.
+	TraceStateHeader   = "tracestate"
+	traceParentVersion = "00"
+)
+
+var (
+	propagators      = []Propagator{XRayPropagator{}}
+	propagatorsMutex = &sync.RWMutex{}
+)
+
+// Carrier represents the headers a trace header is extracted from or injected
+// into.
+type Carrier interface {
+	Get(key string) string
+	Set(key string, value string)
+}
+
+// HTTPHeaderCarrier represents HTTP headers as a Carrier.
+type HTTPHeaderCarrier http.Header
+
+// Get returns the first value of the header, also checking the lowercase key
+// for headers that were not canonicalized.
+func (c HTTPHeaderCarrier) Get(key string) string {
+	if value := http.Header(c).Get(key); value != "" {
+		return value
+	}
+
+	values := c[strings.ToLower(key)]
+	if len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// Set replaces the values of the header.
+func (c HTTPHeaderCarrier) Set(key string, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// MetadataCarrier represents gRPC metadata as a Carrier.  Keys are
+// lowercased, as gRPC requires.
+type MetadataCarrier metadata.MD
+
+// Get returns the first value of the key.
+func (c MetadataCarrier) Get(key string) string {
+	values := c[strings.ToLower(key)]
+	if len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// Set replaces the values of the key.
+func (c MetadataCarrier) Set(key string, value string) {
+	c[strings.ToLower(key)] = []string{value}
+}
+
+// MapCarrier represents a map of strings, such as message headers, as a
+// Carrier.
+type MapCarrier map[string]string
+
+// Get returns the value of the key.
+func (c MapCarrier) Get(key string) string {
+	return c[key]
+}
+
+// Set sets the value of the key.
+func (c MapCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+// MessageAttribute represents a string message attribute in the form used by
+// SQS and SNS.
+type MessageAttribute struct {
+	DataType    string
+	StringValue string
+}
+
+// MessageAttributeCarrier represents the attributes of a queue message as a
+// Carrier.  Values are set as "String" attributes, and only string attributes
+// are read.
+type MessageAttributeCarrier map[string]MessageAttribute
+
+// Get returns the string value of the attribute.
+func (c MessageAttributeCarrier) Get(key string) string {
+	attribute, ok := c[key]
+	if !ok || !strings.HasPrefix(attribute.DataType, "String") {
+		return ""
+	}
+
+	return attribute.StringValue
+}
+
+// Set sets the attribute to a string value.
+func (c MessageAttributeCarrier) Set(key string, value string) {
+	c[key] = MessageAttribute{DataType: "String", StringValue: value}
+}
+
+// Propagator represents a format for passing trace headers between services.
+type Propagator interface {
+	// Extract returns the trace header in the carrier, or an error if it is
+	// missing or invalid.
+	Extract(carrier Carrier) (*TraceHeader, error)
+	// Inject adds the trace header to the carrier.
+	Inject(carrier Carrier, header *TraceHeader)
+}
+
+// SetPropagators updates the formats used to extract and inject trace
+// headers.  Trace headers are extracted with the first propagator to find a
+// valid header, so earlier propagators take precedence when a request has
+// more than one, and injected with all of them.
+func SetPropagators(p ...Propagator) {
+	propagatorsMutex.Lock()
+	defer propagatorsMutex.Unlock()
+	propagators = p
+}
+
+func getPropagators() []Propagator {
+	propagatorsMutex.RLock()
+	defer propagatorsMutex.RUnlock()
+	return propagators
+}
+
+// ExtractTraceHeader returns the trace header in the carrier using the
+// configured propagators.
+func ExtractTraceHeader(carrier Carrier) (*TraceHeader, error) {
+	err := errors.New("no propagators configured")
+	for _, propagator := range getPropagators() {
+		var header *TraceHeader
+		if header, err = propagator.Extract(carrier); err == nil {
+			return header, nil
+		}
+	}
+
+	return nil, err
+}
+
+// InjectTraceHeader adds the trace header to the carrier using the configured
+// propagators.
+func InjectTraceHeader(carrier Carrier, header *TraceHeader) {
+	for _, propagator := range getPropagators() {
+		propagator.Inject(carrier, header)
+	}
+}
+
+// XRayPropagator propagates trace headers in the X-Amzn-Trace-Id header.
+type XRayPropagator struct{}
+
+// Extract parses the X-Amzn-Trace-Id header.
+func (XRayPropagator) Extract(carrier Carrier) (*TraceHeader, error) {
+	value := carrier.Get(XRayHeader)
+	if value == "" {
+		return nil, fmt.Errorf("missing %s header", XRayHeader)
+	}
+
+	return ParseTraceHeader(value)
+}
+
+// Inject sets the X-Amzn-Trace-Id header.
+func (XRayPropagator) Inject(carrier Carrier, header *TraceHeader) {
+	carrier.Set(XRayHeader, header.String())
+}
+
+// W3CPropagator propagates trace headers in the W3C Trace Context traceparent
+// and tracestate headers.  X-Ray trace IDs are converted to W3C trace IDs by
+// joining the epoch and random parts, and back by splitting them.
+type W3CPropagator struct{}
+
+// Extract parses the traceparent header, and keeps the tracestate header so
+// it can be forwarded.
+func (W3CPropagator) Extract(carrier Carrier) (*TraceHeader, error) {
+	value := strings.TrimSpace(carrier.Get(TraceParentHeader))
+	if value == "" {
+		return nil, fmt.Errorf("missing %s header", TraceParentHeader)
+	}
+
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || !isHex(parts[0]) ||
+		parts[0] == "ff" || (parts[0] == traceParentVersion && len(parts) != 4) {
+		return nil, fmt.Errorf("invalid %s header %q", TraceParentHeader, value)
+	}
+
+	traceID, parentID, flags := parts[1], parts[2], parts[3]
+	if len(traceID) != 32 || !isHex(traceID) || isZero(traceID) ||
+		!ValidParentID(parentID) || isZero(parentID) ||
+		len(flags) != 2 || !isHex(flags) {
+		return nil, fmt.Errorf("invalid %s header %q", TraceParentHeader, value)
+	}
+
+	header := &TraceHeader{
+		Root:       traceIDFromHex(traceID),
+		Parent:     parentID,
+		Sampled:    "0",
+		TraceState: strings.TrimSpace(carrier.Get(TraceStateHeader)),
+	}
+
+	// The sampled flag is the lowest bit of the trace flags.
+	if strings.ContainsAny(flags[1:], "13579bdf") {
+		header.Sampled = "1"
+	}
+
+	return header, nil
+}
+
+// Inject sets the traceparent header, and the tracestate header if one was
+// received.  Headers without a valid trace ID and parent ID are not injected.
+func (W3CPropagator) Inject(carrier Carrier, header *TraceHeader) {
+	if !ValidTraceID(header.Root) || !ValidParentID(header.Parent) {
+		return
+	}
+
+	flags := "00"
+	if header.Sampled == "1" {
+		flags = "01"
+	}
+
+	carrier.Set(TraceParentHeader, fmt.Sprintf("%s-%s-%s-%s",
+		traceParentVersion, traceIDToHex(header.Root), header.Parent, flags))
+
+	if header.TraceState != "" {
+		carrier.Set(TraceStateHeader, header.TraceState)
+	}
+}
+
+// traceIDFromHex converts a 128-bit hexadecimal trace ID to an X-Ray trace ID.
+func traceIDFromHex(traceID string) string {
+	return fmt.Sprintf("%s-%s-%s", traceIDVersion, traceID[:traceIDTimeLen],
+		traceID[traceIDTimeLen:])
+}
+
+// traceIDToHex converts a valid X-Ray trace ID to a 128-bit hexadecimal trace
+// ID.
+func traceIDToHex(traceID string) string {
+	return strings.Replace(traceID[len(traceIDVersion)+1:], "-", "", 1)
+}
+
+// isZero returns whether the string is only zeros.
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
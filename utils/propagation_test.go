@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const testTraceParent = "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"
+
+func TestW3CPropagatorExtract(t *testing.T) {
+	tests := []struct {
+		traceParent string
+		traceState  string
+		expect      *TraceHeader
+	}{
+		{
+			traceParent: testTraceParent,
+			traceState:  "vendor=value",
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID,
+				Sampled: "1", TraceState: "vendor=value"},
+		},
+		{
+			traceParent: "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-00",
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID,
+				Sampled: "0"},
+		},
+		{
+			traceParent: "01-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-03-future",
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID,
+				Sampled: "1"},
+		},
+		{traceParent: ""},
+		{traceParent: "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01-extra"},
+		{traceParent: "ff-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"},
+		{traceParent: "00-00000000000000000000000000000000-53995c3f42cd8ad8-01"},
+		{traceParent: "00-5759e988bd862e3fe1be46a994272793-0000000000000000-01"},
+		{traceParent: "00-5759E988BD862E3FE1BE46A994272793-53995c3f42cd8ad8-01"},
+		{traceParent: "00-5759e988bd862e3fe1be46a99427279-53995c3f42cd8ad8-01"},
+		{traceParent: "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-1"},
+	}
+
+	for _, test := range tests {
+		carrier := HTTPHeaderCarrier(http.Header{})
+		if test.traceParent != "" {
+			carrier.Set(TraceParentHeader, test.traceParent)
+		}
+		if test.traceState != "" {
+			carrier.Set(TraceStateHeader, test.traceState)
+		}
+
+		header, err := W3CPropagator{}.Extract(carrier)
+		if test.expect == nil {
+			if err == nil {
+				t.Errorf("Expected error extracting '%s'", test.traceParent)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error extracting '%s': %s", test.traceParent, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(header, test.expect) {
+			t.Errorf("Expected header %+v, got %+v", test.expect, header)
+		}
+	}
+}
+
+func TestW3CPropagatorInject(t *testing.T) {
+	carrier := HTTPHeaderCarrier(http.Header{})
+	W3CPropagator{}.Inject(carrier, &TraceHeader{Root: testTraceID,
+		Parent: testParentID, Sampled: "1", TraceState: "vendor=value"})
+
+	if value := carrier.Get(TraceParentHeader); value != testTraceParent {
+		t.Errorf("Expected traceparent '%s', got '%s'", testTraceParent, value)
+	}
+
+	if value := carrier.Get(TraceStateHeader); value != "vendor=value" {
+		t.Errorf("Expected tracestate 'vendor=value', got '%s'", value)
+	}
+
+	carrier = HTTPHeaderCarrier(http.Header{})
+	W3CPropagator{}.Inject(carrier, &TraceHeader{Root: testTraceID})
+
+	if value := carrier.Get(TraceParentHeader); value != "" {
+		t.Errorf("Header without a parent should not be injected, got '%s'",
+			value)
+	}
+}
+
+func TestExtractTraceHeaderPrecedence(t *testing.T) {
+	defer SetPropagators(getPropagators()...)
+
+	otherTraceID := "1-5759e988-000000000000000000000001"
+	carrier := HTTPHeaderCarrier(http.Header{})
+	carrier.Set(XRayHeader, "Root="+otherTraceID)
+	carrier.Set(TraceParentHeader, testTraceParent)
+
+	tests := []struct {
+		propagators []Propagator
+		expectRoot  string
+	}{
+		{[]Propagator{XRayPropagator{}}, otherTraceID},
+		{[]Propagator{XRayPropagator{}, W3CPropagator{}}, otherTraceID},
+		{[]Propagator{W3CPropagator{}, XRayPropagator{}}, testTraceID},
+	}
+
+	for _, test := range tests {
+		SetPropagators(test.propagators...)
+
+		header, err := ExtractTraceHeader(carrier)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if header.Root != test.expectRoot {
+			t.Errorf("Expected root '%s', got '%s'", test.expectRoot, header.Root)
+		}
+	}
+
+	SetPropagators(XRayPropagator{}, W3CPropagator{})
+	carrier = HTTPHeaderCarrier(http.Header{})
+	carrier.Set(XRayHeader, "Root=invalid")
+	carrier.Set(TraceParentHeader, testTraceParent)
+
+	if header, err := ExtractTraceHeader(carrier); err != nil ||
+		header.Root != testTraceID {
+		t.Error("An invalid X-Ray header should fall back to traceparent")
+	}
+
+	SetPropagators()
+	if _, err := ExtractTraceHeader(carrier); err == nil {
+		t.Error("Extracting without propagators should error")
+	}
+}
+
+func TestCarriers(t *testing.T) {
+	carriers := []Carrier{
+		HTTPHeaderCarrier(http.Header{}),
+		MetadataCarrier{},
+		MapCarrier{},
+		MessageAttributeCarrier{},
+	}
+
+	for _, carrier := range carriers {
+		XRayPropagator{}.Inject(carrier, &TraceHeader{Root: testTraceID,
+			Parent: testParentID, Sampled: "1"})
+
+		header, err := XRayPropagator{}.Extract(carrier)
+		if err != nil {
+			t.Errorf("Unexpected error extracting from %T: %s", carrier, err)
+			continue
+		}
+
+		if header.Root != testTraceID || header.Parent != testParentID {
+			t.Errorf("Expected the injected header from %T, got %+v", carrier,
+				header)
+		}
+	}
+
+	attributes := MessageAttributeCarrier{
+		XRayHeader: {DataType: "Binary", StringValue: "Root=" + testTraceID},
+	}
+	if value := attributes.Get(XRayHeader); value != "" {
+		t.Errorf("Binary attributes should not be read, got '%s'", value)
+	}
+}
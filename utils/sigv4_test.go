@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// Example request from the AWS Signature Version 4 documentation.
+	reqURL, _ := url.Parse("https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08")
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    reqURL,
+		Header: http.Header{
+			"Content-Type": []string{"application/x-www-form-urlencoded; charset=utf-8"},
+		},
+	}
+
+	credentials := Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	SignV4(req, nil, credentials, "us-east-1", "iam", now)
+
+	expect := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/" +
+		"aws4_request, SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+
+	if auth := req.Header.Get("Authorization"); auth != expect {
+		t.Errorf("Expected authorization '%s', got '%s'", expect, auth)
+	}
+
+	if date := req.Header.Get("X-Amz-Date"); date != "20150830T123600Z" {
+		t.Errorf("Expected date '20150830T123600Z', got '%s'", date)
+	}
+
+	credentials.SessionToken = "token"
+	SignV4(req, nil, credentials, "us-east-1", "iam", now)
+
+	if req.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Error("Session token header should be set")
+	}
+
+	if !strings.Contains(req.Header.Get("Authorization"), "x-amz-security-token") {
+		t.Error("Session token header should be signed")
+	}
+}
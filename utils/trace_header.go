@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	traceIDVersion   = "1"
+	traceIDTimeLen   = 8
+	traceIDRandomLen = 24
+	parentIDLen      = 16
+)
+
+// TraceHeaderField represents a key-value pair of a trace header that is not
+// interpreted by the SDK.
+type TraceHeaderField struct {
+	Key   string
+	Value string
+}
+
+// TraceHeader represents an X-Amzn-Trace-Id header.  Keys other than Root,
+// Parent, Sampled, Self and Lineage are kept in Data, in order, so they can
+// be forwarded downstream.  TraceState holds a W3C tracestate header received
+// with the trace, which is forwarded by the W3C propagator.
+type TraceHeader struct {
+	Root       string
+	Parent     string
+	Sampled    string
+	Self       string
+	Lineage    string
+	Data       []TraceHeaderField
+	TraceState string
+}
+
+// ParseTraceHeader parses an X-Amzn-Trace-Id header.  It errors if the root
+// trace ID is missing or invalid, or the parent ID is invalid.  Pairs without
+// a value and unknown sampling decisions are ignored.
+func ParseTraceHeader(header string) (*TraceHeader, error) {
+	traceHeader := &TraceHeader{}
+
+	for _, pair := range strings.Split(header, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if key == "" || value == "" {
+			continue
+		}
+
+		switch key {
+		case "Root":
+			traceHeader.Root = value
+		case "Parent":
+			traceHeader.Parent = value
+		case "Sampled":
+			if value == "0" || value == "1" || value == "?" {
+				traceHeader.Sampled = value
+			}
+		case "Self":
+			traceHeader.Self = value
+		case "Lineage":
+			traceHeader.Lineage = value
+		default:
+			traceHeader.Data = append(traceHeader.Data,
+				TraceHeaderField{Key: key, Value: value})
+		}
+	}
+
+	if traceHeader.Root == "" {
+		return nil, errors.New("missing root trace ID in trace header")
+	}
+
+	if !ValidTraceID(traceHeader.Root) {
+		return nil, fmt.Errorf("invalid root trace ID %q in trace header",
+			traceHeader.Root)
+	}
+
+	if traceHeader.Parent != "" && !ValidParentID(traceHeader.Parent) {
+		return nil, fmt.Errorf("invalid parent ID %q in trace header",
+			traceHeader.Parent)
+	}
+
+	return traceHeader, nil
+}
+
+// String returns the header value to send downstream.  Self is not included,
+// as it only describes the sender's own segment.
+func (h *TraceHeader) String() string {
+	pairs := []string{"Root=" + h.Root}
+
+	if h.Parent != "" {
+		pairs = append(pairs, "Parent="+h.Parent)
+	}
+
+	if h.Sampled != "" {
+		pairs = append(pairs, "Sampled="+h.Sampled)
+	}
+
+	if h.Lineage != "" {
+		pairs = append(pairs, "Lineage="+h.Lineage)
+	}
+
+	for _, field := range h.Data {
+		pairs = append(pairs, field.Key+"="+field.Value)
+	}
+
+	return strings.Join(pairs, ";")
+}
+
+// ValidTraceID returns whether the trace ID is in the "1-8hex-24hex" format.
+func ValidTraceID(traceID string) bool {
+	parts := strings.Split(traceID, "-")
+
+	return len(parts) == 3 &&
+		parts[0] == traceIDVersion &&
+		len(parts[1]) == traceIDTimeLen && isHex(parts[1]) &&
+		len(parts[2]) == traceIDRandomLen && isHex(parts[2])
+}
+
+// ValidParentID returns whether the parent ID is 16 hexadecimal digits.
+func ValidParentID(parentID string) bool {
+	return len(parentID) == parentIDLen && isHex(parentID)
+}
+
+// isHex returns whether the string is only lowercase hexadecimal digits.
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
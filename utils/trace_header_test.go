@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	testTraceID  = "1-5759e988-bd862e3fe1be46a994272793"
+	testParentID = "53995c3f42cd8ad8"
+)
+
+func TestParseTraceHeader(t *testing.T) {
+	tests := []struct {
+		header      string
+		expect      *TraceHeader
+		expectError bool
+	}{
+		{
+			header: "Root=" + testTraceID,
+			expect: &TraceHeader{Root: testTraceID},
+		},
+		{
+			header: "Root=" + testTraceID + ";Parent=" + testParentID + ";Sampled=1",
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID, Sampled: "1"},
+		},
+		{
+			header: " Root=" + testTraceID + "; Parent=" + testParentID +
+				"; Sampled=?; ",
+			expect: &TraceHeader{Root: testTraceID, Parent: testParentID, Sampled: "?"},
+		},
+		{
+			header: "Self=1-67891234-12456789abcdef012345678;Root=" + testTraceID +
+				";Lineage=a87bd80c:0;CalledFrom=ALB;Custom=a=b",
+			expect: &TraceHeader{
+				Root:    testTraceID,
+				Self:    "1-67891234-12456789abcdef012345678",
+				Lineage: "a87bd80c:0",
+				Data: []TraceHeaderField{
+					{Key: "CalledFrom", Value: "ALB"},
+					{Key: "Custom", Value: "a=b"},
+				},
+			},
+		},
+		{
+			header: "Root=" + testTraceID + ";Sampled=yes;Parent;Key=",
+			expect: &TraceHeader{Root: testTraceID},
+		},
+		{header: "", expectError: true},
+		{header: "Parent=" + testParentID, expectError: true},
+		{header: "Root=123", expectError: true},
+		{header: "Root=2-5759e988-bd862e3fe1be46a994272793", expectError: true},
+		{header: "Root=1-5759e98-bd862e3fe1be46a9942727930", expectError: true},
+		{header: "Root=1-5759E988-BD862E3FE1BE46A994272793", expectError: true},
+		{header: "Root=1-5759e988-bd862e3fe1be46a99427279z", expectError: true},
+		{header: "Root=" + testTraceID + ";Parent=456", expectError: true},
+	}
+
+	for _, test := range tests {
+		header, err := ParseTraceHeader(test.header)
+		if test.expectError {
+			if err == nil {
+				t.Errorf("Expected error parsing '%s'", test.header)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error parsing '%s': %s", test.header, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(header, test.expect) {
+			t.Errorf("Expected header %+v, got %+v", test.expect, header)
+		}
+	}
+}
+
+func TestTraceHeaderString(t *testing.T) {
+	header := &TraceHeader{
+		Root:    testTraceID,
+		Parent:  testParentID,
+		Sampled: "1",
+		Self:    "1-67891234-12456789abcdef012345678",
+		Lineage: "a87bd80c:0",
+		Data:    []TraceHeaderField{{Key: "CalledFrom", Value: "ALB"}},
+	}
+
+	expected := "Root=" + testTraceID + ";Parent=" + testParentID +
+		";Sampled=1;Lineage=a87bd80c:0;CalledFrom=ALB"
+	if header.String() != expected {
+		t.Errorf("Expected header '%s', got '%s'", expected, header.String())
+	}
+}
+
+func FuzzParseTraceHeader(f *testing.F) {
+	f.Add("Root=" + testTraceID + ";Parent=" + testParentID + ";Sampled=1")
+	f.Add("Self=1-67891234-12456789abcdef012345678;Root=" + testTraceID +
+		";Lineage=a87bd80c:0;CalledFrom=ALB")
+	f.Add("Root=" + testTraceID + ";;=;Key==value; Sampled=?")
+
+	f.Fuzz(func(t *testing.T, value string) {
+		header, err := ParseTraceHeader(value)
+		if err != nil {
+			return
+		}
+
+		// Serialized headers parse to the same header, without Self.
+		reparsed, err := ParseTraceHeader(header.String())
+		if err != nil {
+			t.Fatalf("Error parsing serialized header '%s': %s",
+				header.String(), err)
+		}
+
+		header.Self = ""
+		if !reflect.DeepEqual(header, reparsed) {
+			t.Errorf("Expected header %+v, got %+v", header, reparsed)
+		}
+	})
+}
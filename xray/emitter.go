@@ -0,0 +1,26 @@
+package xray
+
+import "github.com/goguardian/aws-xray-go/segment"
+
+// SetEmitter updates the emitter used to send segments.
+func SetEmitter(e segment.Emitter) {
+	segment.SetEmitter(e)
+}
+
+// SetStreamingConfig updates the thresholds at which completed subsegments are
+// sent independently of their segment.
+func SetStreamingConfig(config segment.StreamingConfig) {
+	segment.SetStreamingConfig(config)
+}
+
+// SetInProgressConfig updates whether in-progress segments are sent before
+// segments are closed.
+func SetInProgressConfig(config segment.InProgressConfig) {
+	segment.SetInProgressConfig(config)
+}
+
+// GetEmitterStats returns counters describing the health of segment emission.
+// The counters are also published with expvar.
+func GetEmitterStats() segment.EmitterStats {
+	return segment.GetEmitterStats()
+}
@@ -0,0 +1,44 @@
+package xray
+
+import (
+	"context"
+	"github.com/goguardian/aws-xray-go/segment"
+	"testing"
+)
+
+func TestSetEmitter(t *testing.T) {
+	original := segment.GetEmitter()
+	defer SetEmitter(original)
+
+	memory := segment.NewMemoryEmitter()
+	SetEmitter(memory)
+
+	ctx := NewContext(name, context.Background())
+
+	seg, err := GetSegment(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg.Traced = true
+
+	Close(ctx)
+
+	if len(memory.Documents()) != 1 {
+		t.Errorf("Memory emitter should have 1 document, got %d",
+			len(memory.Documents()))
+	}
+}
+
+func TestSetStreamingConfig(t *testing.T) {
+	SetStreamingConfig(segment.StreamingConfig{MaxSubsegments: 100})
+}
+
+func TestSetInProgressConfig(t *testing.T) {
+	SetInProgressConfig(segment.InProgressConfig{})
+}
+
+func TestGetEmitterStats(t *testing.T) {
+	if stats := GetEmitterStats(); stats.SegmentsSent < 0 {
+		t.Error("Segments sent should not be negative")
+	}
+}
@@ -0,0 +1,42 @@
+package xray
+
+import (
+	"context"
+	"fmt"
+	"github.com/goguardian/aws-xray-go/segment"
+	"github.com/goguardian/aws-xray-go/utils"
+)
+
+// Go runs fn in a new goroutine traced by a subsegment of the current
+// subsegment or segment of the context.  The segment is not sent until fn
+// returns, so Go must be called before the segment is closed.  An error
+// returned by fn marks the subsegment as an error, and a panic marks it as a
+// fault before it is re-raised.  Their messages are recorded as metadata.
+// Without a segment in the context, fn is run untraced.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	if _, ok := segment.FromContext(ctx); !ok {
+		go fn(ctx)
+		return
+	}
+
+	// The subsegment is added before the goroutine starts so the segment
+	// counts it as open.
+	ctx, subseg := BeginSubsegment(ctx, name)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				subseg.AddMetadata("panic", fmt.Sprint(r))
+				subseg.Close(fmt.Errorf("panic: %v", r), utils.FaultType)
+				panic(r)
+			}
+		}()
+
+		err := fn(ctx)
+		if err != nil {
+			subseg.AddMetadata("error", err.Error())
+		}
+
+		subseg.Close(err, utils.ErrorType)
+	}()
+}
@@ -0,0 +1,80 @@
+package xray
+
+import (
+	"context"
+	"errors"
+	"github.com/goguardian/aws-xray-go/segment"
+	"testing"
+	"time"
+)
+
+func TestGo(t *testing.T) {
+	original := segment.GetEmitter()
+	defer SetEmitter(original)
+
+	memory := segment.NewMemoryEmitter()
+	SetEmitter(memory)
+
+	ctx := NewContext(name, context.Background())
+	seg, _ := GetSegment(ctx)
+	seg.Traced = true
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	Go(ctx, "background", func(ctx context.Context) error {
+		defer close(done)
+		<-release
+
+		Go(ctx, "nested", func(ctx context.Context) error {
+			return nil
+		})
+
+		return errors.New("failed")
+	})
+
+	Close(ctx)
+
+	if len(memory.Documents()) != 0 {
+		t.Fatal("Segment should not be sent while the goroutine runs")
+	}
+
+	close(release)
+	<-done
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(memory.Documents()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(memory.Documents()) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(memory.Documents()))
+	}
+
+	seg.RLock()
+	defer seg.RUnlock()
+
+	if len(seg.Subsegments) != 1 || !seg.Subsegments[0].Error {
+		t.Fatal("Goroutine subsegment should be marked error")
+	}
+
+	if subsegments := seg.Subsegments[0].Subsegments; len(subsegments) != 1 ||
+		subsegments[0].Name != "nested" {
+		t.Error("Nested goroutine should be a subsegment of the goroutine")
+	}
+}
+
+func TestGoWithoutSegment(t *testing.T) {
+	done := make(chan struct{})
+
+	Go(context.Background(), "background", func(ctx context.Context) error {
+		close(done)
+		return nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Function should run without a segment")
+	}
+}
@@ -15,7 +15,10 @@ func NewGRPCServer(name string, opt ...grpc.ServerOption) *grpc.Server {
 	return grpc.NewServer(options...)
 }
 
-// SetSegmentCacheDuration updates the duration of segments in in-memory cache.
+// SetSegmentCacheDuration has no effect.  Segments are carried in the context
+// instead of an in-memory cache.
+//
+// Deprecated: segments are no longer cached.
 func SetSegmentCacheDuration(duration time.Duration) {
 	handlers.SetSegmentCacheDuration(duration)
 }
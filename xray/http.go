@@ -3,6 +3,7 @@ package xray
 import (
 	"context"
 	"github.com/goguardian/aws-xray-go/handlers"
+	"github.com/goguardian/aws-xray-go/sampling"
 	"github.com/goguardian/aws-xray-go/utils"
 	"net/http"
 )
@@ -18,16 +19,27 @@ func GetHTTPClient(ctx context.Context) (*http.Client, error) {
 	return handlers.NewHTTPClient(segment), nil
 }
 
-// Middleware provides a middleware for tracing HTTP handlers.
+// Middleware provides a middleware for tracing HTTP handlers.  The trace ID,
+// and the sampling decision when requested with "Sampled=?", is returned in
+// the X-Amzn-Trace-Id response header.
 func Middleware(name string, handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := utils.ContextFromHeaders(r)
+		ctx = sampling.NewContext(ctx, &sampling.Request{
+			Host:    r.Host,
+			Method:  r.Method,
+			URLPath: r.URL.Path,
+		})
 
 		r = r.WithContext(NewContext(name, ctx))
 		defer Close(r.Context())
 
 		AddLocalHTTP(r)
 
+		if seg, err := GetSegment(r.Context()); err == nil {
+			w.Header().Set(utils.XRayHeader, seg.ResponseHeader().String())
+		}
+
 		handler(w, r)
 	}
 }
@@ -1,6 +1,12 @@
 package xray
 
-import "testing"
+import (
+	"github.com/goguardian/aws-xray-go/segment"
+	"github.com/goguardian/aws-xray-go/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
 
 func TestGetHTTPClient(t *testing.T) {
 	ctx := NewContext(name, nil)
@@ -13,3 +19,27 @@ func TestGetHTTPClient(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestMiddlewareResponseHeader(t *testing.T) {
+	var seg *segment.Segment
+	handler := Middleware(name, func(w http.ResponseWriter, r *http.Request) {
+		seg, _ = GetSegment(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(utils.XRayHeader,
+		"Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=?")
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+
+	sampled := "0"
+	if seg.Traced {
+		sampled = "1"
+	}
+
+	expected := "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=" + sampled
+	if header := recorder.Header().Get(utils.XRayHeader); header != expected {
+		t.Errorf("Expected response header '%s', got '%s'", expected, header)
+	}
+}
@@ -0,0 +1,50 @@
+package xray
+
+import (
+	"errors"
+	"github.com/goguardian/aws-xray-go/segment"
+	"github.com/goguardian/aws-xray-go/utils"
+
+	"golang.org/x/net/context"
+)
+
+// SetPropagators updates the trace header formats used by the HTTP middleware
+// and clients, in order of precedence.  For example, W3C traceparent headers
+// are accepted and sent alongside X-Ray headers with:
+//
+//	xray.SetPropagators(utils.XRayPropagator{}, utils.W3CPropagator{})
+func SetPropagators(p ...utils.Propagator) {
+	utils.SetPropagators(p...)
+}
+
+// Inject adds the trace header of the context segment to the carrier, for
+// example the attributes of a queue message, with the configured propagators.
+// The current subsegment of the context, or the segment, is the parent.
+func Inject(ctx context.Context, carrier utils.Carrier) error {
+	seg, ok := segment.FromContext(ctx)
+	if !ok {
+		return errors.New("Segment not found in context")
+	}
+
+	parentID := seg.ID
+	if subseg, ok := segment.SubsegmentFromContext(ctx); ok {
+		parentID = subseg.ID
+	}
+
+	utils.InjectTraceHeader(carrier, seg.DownstreamHeader(parentID))
+
+	return nil
+}
+
+// Extract returns a copy of the context carrying the trace header in the
+// carrier, so a segment created with NewContext continues the trace.  The
+// context is returned unchanged when the carrier has no valid trace header.
+// The context should not already have a segment, which would take precedence.
+func Extract(ctx context.Context, carrier utils.Carrier) context.Context {
+	header, err := utils.ExtractTraceHeader(carrier)
+	if err != nil {
+		return ctx
+	}
+
+	return utils.NewTraceHeaderContext(ctx, header)
+}
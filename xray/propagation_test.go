@@ -0,0 +1,118 @@
+package xray
+
+import (
+	"context"
+	"github.com/goguardian/aws-xray-go/segment"
+	"github.com/goguardian/aws-xray-go/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetPropagators(t *testing.T) {
+	defer SetPropagators(utils.XRayPropagator{})
+	SetPropagators(utils.XRayPropagator{}, utils.W3CPropagator{})
+
+	var traceParent string
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			traceParent = r.Header.Get(utils.TraceParentHeader)
+		}))
+	defer upstream.Close()
+
+	handler := Middleware(name, func(w http.ResponseWriter, r *http.Request) {
+		client, err := GetHTTPClient(r.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Get(upstream.URL); err != nil {
+			t.Error(err)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(utils.TraceParentHeader,
+		"00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01")
+	handler(httptest.NewRecorder(), req)
+
+	if !strings.HasPrefix(traceParent,
+		"00-5759e988bd862e3fe1be46a994272793-") {
+		t.Errorf("Outbound traceparent should continue the trace, got '%s'",
+			traceParent)
+	}
+}
+
+func TestB3SamplingOnly(t *testing.T) {
+	defer SetPropagators(utils.XRayPropagator{})
+	SetPropagators(utils.XRayPropagator{}, utils.B3Propagator{})
+	SetSampler(0, 1)
+
+	traced := true
+	handler := Middleware(name, func(w http.ResponseWriter, r *http.Request) {
+		seg, err := GetSegment(r.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		traced = seg.Traced
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(utils.B3Header, "0")
+	handler(httptest.NewRecorder(), req)
+
+	if traced {
+		t.Error("Upstream B3 decision not to sample should be honored")
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	producerCtx := NewContext("producer", context.Background())
+	producer, _ := GetSegment(producerCtx)
+
+	attributes := utils.MessageAttributeCarrier{}
+	if err := Inject(producerCtx, attributes); err != nil {
+		t.Fatal(err)
+	}
+
+	if attributes[utils.XRayHeader].DataType != "String" {
+		t.Error("Trace header should be injected as a string attribute")
+	}
+
+	consumerCtx := NewContext("consumer",
+		Extract(context.Background(), attributes))
+	consumer, _ := GetSegment(consumerCtx)
+
+	if consumer.TraceID != producer.TraceID {
+		t.Errorf("Expected trace ID '%s', got '%s'", producer.TraceID,
+			consumer.TraceID)
+	}
+
+	if consumer.ParentID != producer.ID {
+		t.Errorf("Expected parent ID '%s', got '%s'", producer.ID,
+			consumer.ParentID)
+	}
+
+	subseg := producer.AddNewSubsegment("send")
+	headers := utils.MapCarrier{}
+	Inject(segment.NewSubsegmentContext(producerCtx, subseg), headers)
+
+	header, err := utils.ParseTraceHeader(headers[utils.XRayHeader])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Parent != subseg.ID {
+		t.Errorf("Expected parent ID '%s', got '%s'", subseg.ID, header.Parent)
+	}
+
+	if err := Inject(context.Background(), headers); err == nil {
+		t.Error("Inject without a segment should error")
+	}
+
+	if ctx := Extract(context.Background(), utils.MapCarrier{}); ctx !=
+		context.Background() {
+		t.Error("Extract without a trace header should not change the context")
+	}
+}
@@ -1,6 +1,7 @@
 package xray
 
 import (
+	"github.com/goguardian/aws-xray-go/sampling"
 	"github.com/goguardian/aws-xray-go/segment"
 	"github.com/goguardian/aws-xray-go/utils"
 )
@@ -9,3 +10,24 @@ import (
 func SetSampler(fixedTarget uint32, fallbackRate float64) {
 	segment.SetSampler(utils.NewSampler(fixedTarget, fallbackRate))
 }
+
+// SetSamplingStrategy updates the strategy deciding whether segments without
+// an upstream sampling decision are traced, such as a
+// sampling.CentralizedStrategy.
+func SetSamplingStrategy(strategy sampling.Strategy) {
+	segment.SetSamplingStrategy(strategy)
+}
+
+// SetSamplingRules updates segment sampling to follow the rules of a local
+// sampling rules file.  The sampling strategy is unchanged if the file is not
+// valid.
+func SetSamplingRules(path string) error {
+	strategy, err := sampling.NewLocalStrategyFromFile(path)
+	if err != nil {
+		return err
+	}
+
+	segment.SetSamplingStrategy(strategy)
+
+	return nil
+}
@@ -1,7 +1,39 @@
 package xray
 
-import "testing"
+import (
+	"github.com/goguardian/aws-xray-go/sampling"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestSetSampler(t *testing.T) {
 	SetSampler(1, 1)
 }
+
+func TestSetSamplingStrategy(t *testing.T) {
+	SetSamplingStrategy(sampling.NewSamplerStrategy(1, 1))
+}
+
+func TestSetSamplingRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xray")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	rules := `{"version": 2, "default": {"fixed_target": 1, "rate": 1}}`
+	if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetSamplingRules(path); err != nil {
+		t.Error(err)
+	}
+
+	if err := SetSamplingRules(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Missing rules file should error")
+	}
+}
@@ -0,0 +1,107 @@
+package xray
+
+import (
+	"context"
+	"fmt"
+	"github.com/goguardian/aws-xray-go/segment"
+	"github.com/goguardian/aws-xray-go/utils"
+	"time"
+)
+
+// SegmentOption represents an option of a segment started with BeginSegment.
+type SegmentOption func(*segmentOptions)
+
+type segmentOptions struct {
+	header    utils.TraceHeader
+	startTime time.Time
+}
+
+// WithTraceHeader continues the trace of a parsed trace header.  A nil header
+// has no effect.
+func WithTraceHeader(header *utils.TraceHeader) SegmentOption {
+	return func(o *segmentOptions) {
+		if header != nil {
+			o.header = *header
+		}
+	}
+}
+
+// WithTraceID sets the trace ID of the segment.
+func WithTraceID(traceID string) SegmentOption {
+	return func(o *segmentOptions) {
+		o.header.Root = traceID
+	}
+}
+
+// WithParentID sets the parent ID of the segment.
+func WithParentID(parentID string) SegmentOption {
+	return func(o *segmentOptions) {
+		o.header.Parent = parentID
+	}
+}
+
+// WithStartTime sets the start time of the segment.
+func WithStartTime(startTime time.Time) SegmentOption {
+	return func(o *segmentOptions) {
+		o.startTime = startTime
+	}
+}
+
+// WithSampled overrides the sampling decision of the segment.
+func WithSampled(sampled bool) SegmentOption {
+	return func(o *segmentOptions) {
+		o.header.Sampled = "0"
+		if sampled {
+			o.header.Sampled = "1"
+		}
+	}
+}
+
+// BeginSegment creates a new segment for work that is not triggered by a
+// request, such as a scheduled job or a queue message, and returns a copy of
+// the context carrying it.  The segment continues the trace header of the
+// context, if any, as added by Extract, and the options override it.  Any
+// segment already in the context is ignored.  It errors if the trace ID or
+// parent ID are invalid, or a parent ID is given without a trace ID.
+func BeginSegment(
+	ctx context.Context,
+	name string,
+	opts ...SegmentOption,
+) (context.Context, *segment.Segment, error) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	options := &segmentOptions{}
+	if header, ok := utils.TraceHeaderFromContext(ctx); ok {
+		options.header = *header
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	header := &options.header
+	if header.Root != "" && !utils.ValidTraceID(header.Root) {
+		return ctx, nil, fmt.Errorf("invalid trace ID %q", header.Root)
+	}
+
+	if header.Parent != "" && !utils.ValidParentID(header.Parent) {
+		return ctx, nil, fmt.Errorf("invalid parent ID %q", header.Parent)
+	}
+
+	if header.Parent != "" && header.Root == "" {
+		return ctx, nil, fmt.Errorf("parent ID %q without a trace ID",
+			header.Parent)
+	}
+
+	startTime := 0.0
+	if !options.startTime.IsZero() {
+		startTime = float64(options.startTime.UnixNano()) / float64(time.Second)
+	}
+
+	seg := segment.NewWithTraceHeader(name, header, startTime)
+
+	return segment.NewContext(ctx, seg), seg, nil
+}
@@ -0,0 +1,77 @@
+package xray
+
+import (
+	"context"
+	"github.com/goguardian/aws-xray-go/utils"
+	"testing"
+	"time"
+)
+
+func TestBeginSegment(t *testing.T) {
+	traceID := "1-5759e988-bd862e3fe1be46a994272793"
+	parentID := "53995c3f42cd8ad8"
+	startTime := time.Unix(1465510280, 500000000)
+
+	ctx, seg, err := BeginSegment(context.Background(), "job",
+		WithTraceID(traceID), WithParentID(parentID),
+		WithStartTime(startTime), WithSampled(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result, _ := GetSegment(ctx); result != seg {
+		t.Error("Context should carry the segment")
+	}
+
+	if seg.TraceID != traceID || seg.ParentID != parentID {
+		t.Errorf("Expected trace ID '%s' and parent ID '%s', got '%s' and '%s'",
+			traceID, parentID, seg.TraceID, seg.ParentID)
+	}
+
+	if seg.StartTime != 1465510280.5 {
+		t.Errorf("Expected start time 1465510280.5, got %f", seg.StartTime)
+	}
+
+	if !seg.Traced {
+		t.Error("Segment should be traced when sampling is forced")
+	}
+
+	header := &utils.TraceHeader{Root: traceID, Parent: parentID, Sampled: "1"}
+	_, seg, err = BeginSegment(ctx, "job", WithTraceHeader(header),
+		WithSampled(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seg.TraceID != traceID || seg.Traced {
+		t.Error("Options should override the trace header")
+	}
+
+	extracted := Extract(context.Background(), utils.MapCarrier{
+		utils.XRayHeader: "Root=" + traceID + ";Parent=" + parentID,
+	})
+	if _, seg, _ = BeginSegment(extracted, "consumer"); seg.TraceID != traceID {
+		t.Error("Segment should continue the trace header of the context")
+	}
+
+	_, seg, err = BeginSegment(extracted, "consumer", WithTraceHeader(nil))
+	if err != nil || seg.TraceID != traceID {
+		t.Error("A nil trace header should have no effect")
+	}
+
+	_, seg, err = BeginSegment(nil, "job")
+	if err != nil || !utils.ValidTraceID(seg.TraceID) {
+		t.Errorf("Segment should start a new trace, got '%s'", seg.TraceID)
+	}
+
+	invalid := [][]SegmentOption{
+		{WithTraceID("123")},
+		{WithTraceID(traceID), WithParentID("456")},
+		{WithParentID(parentID)},
+	}
+	for _, opts := range invalid {
+		if _, _, err := BeginSegment(context.Background(), "job", opts...); err == nil {
+			t.Error("Invalid IDs should error")
+		}
+	}
+}
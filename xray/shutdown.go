@@ -0,0 +1,22 @@
+package xray
+
+import (
+	"context"
+	"github.com/goguardian/aws-xray-go/segment"
+	"time"
+)
+
+// Shutdown stops tracing new segments, closes every open segment and
+// subsegment, and flushes the emitter.  It is intended to be called before the
+// process exits, for example when handling SIGTERM, and returns the context
+// error if the context is done first.
+func Shutdown(ctx context.Context) error {
+	return segment.Shutdown(ctx)
+}
+
+// SetOpenSegmentMaxAge updates how long a traced segment is remembered to be
+// closed by Shutdown.  Segments that are never closed are forgotten after this
+// age.
+func SetOpenSegmentMaxAge(maxAge time.Duration) {
+	segment.SetOpenSegmentMaxAge(maxAge)
+}
@@ -0,0 +1,26 @@
+package xray
+
+import (
+	"context"
+	"github.com/goguardian/aws-xray-go/segment"
+)
+
+// BeginSubsegment adds a new subsegment to the current subsegment of the
+// context, or its segment, and returns a copy of the context with the new
+// subsegment as the current subsegment.  Calls traced with the returned
+// context are recorded as subsegments of the new subsegment.  Without a
+// segment in the context, the subsegment is not recorded.
+func BeginSubsegment(
+	ctx context.Context,
+	name string,
+) (context.Context, *segment.Subsegment) {
+
+	seg, ok := segment.FromContext(ctx)
+	if !ok {
+		return ctx, segment.NewSubsegment(name)
+	}
+
+	subseg := seg.AddNewNestedSubsegment(ctx, name)
+
+	return segment.NewSubsegmentContext(ctx, subseg), subseg
+}
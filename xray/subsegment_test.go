@@ -0,0 +1,33 @@
+package xray
+
+import (
+	"context"
+	"github.com/goguardian/aws-xray-go/segment"
+	"testing"
+)
+
+func TestBeginSubsegment(t *testing.T) {
+	ctx := NewContext(name, context.Background())
+	seg, _ := GetSegment(ctx)
+
+	outerCtx, outer := BeginSubsegment(ctx, "outer")
+	_, inner := BeginSubsegment(outerCtx, "inner")
+
+	if current, _ := segment.SubsegmentFromContext(outerCtx); current != outer {
+		t.Error("Context should carry the new subsegment")
+	}
+
+	if len(seg.Subsegments) != 1 || seg.Subsegments[0] != outer {
+		t.Error("Subsegment should be added to the segment")
+	}
+
+	if len(outer.Subsegments) != 1 || outer.Subsegments[0] != inner {
+		t.Error("Subsegment should be nested under the context subsegment")
+	}
+
+	untracedCtx, untraced := BeginSubsegment(context.Background(), "untraced")
+	if untraced == nil || untracedCtx != context.Background() {
+		t.Error("Subsegment without a segment should not be recorded")
+	}
+	untraced.Close(nil, "")
+}
@@ -2,8 +2,12 @@ package xray
 
 import (
 	"context"
+	"github.com/goguardian/aws-xray-go/segment"
 	"net/http"
+	"os"
+	"os/exec"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc"
 )
@@ -58,3 +62,34 @@ func TestGetGRPCClientConn(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// TestShutdown runs in a subprocess, since shutdown cannot be undone and
+// would stop the other tests from tracing.
+func TestShutdown(t *testing.T) {
+	if os.Getenv("XRAY_TEST_SHUTDOWN") != "1" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestShutdown$")
+		cmd.Env = append(os.Environ(), "XRAY_TEST_SHUTDOWN=1")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Shutdown subprocess failed: %s\n%s", err.Error(), output)
+		}
+		return
+	}
+
+	SetEmitter(segment.NewMemoryEmitter())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+
+	seg, err := GetSegment(NewContext(name, context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seg.Traced {
+		t.Error("Segments created after shutdown should not be traced")
+	}
+}
@@ -0,0 +1,63 @@
+package xraytest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// FindSubsegment returns the first subsegment with the name in the segment's
+// subsegment tree, or nil.
+func FindSubsegment(seg *Segment, name string) *Segment {
+	for _, subseg := range seg.Subsegments {
+		if subseg.Name == name {
+			return subseg
+		}
+
+		if found := FindSubsegment(subseg, name); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// AssertSubsegment fails the test if the segment has no subsegment with the
+// name, and otherwise returns the subsegment.
+func AssertSubsegment(t testing.TB, seg *Segment, name string) *Segment {
+	t.Helper()
+
+	subseg := FindSubsegment(seg, name)
+	if subseg == nil {
+		t.Errorf("Segment %q should have subsegment %q", seg.Name, name)
+	}
+
+	return subseg
+}
+
+// AssertAnnotation fails the test if the segment does not have the annotation
+// with the value.  Values are compared by their JSON representation, so
+// numbers of any type match.
+func AssertAnnotation(t testing.TB, seg *Segment, key string, value interface{}) {
+	t.Helper()
+
+	actual, ok := seg.Annotations[key]
+	if !ok {
+		t.Errorf("Segment %q should have annotation %q", seg.Name, key)
+		return
+	}
+
+	expectJSON, err := json.Marshal(value)
+	if err != nil {
+		t.Errorf("Error encoding annotation value %v: %s", value, err)
+		return
+	}
+
+	var expect interface{}
+	json.Unmarshal(expectJSON, &expect)
+
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("Segment %q annotation %q should be %v, got %v",
+			seg.Name, key, value, actual)
+	}
+}
@@ -0,0 +1,38 @@
+package xraytest
+
+import "testing"
+
+func TestFindSubsegment(t *testing.T) {
+	seg := &Segment{
+		Name: "segment",
+		Subsegments: []*Segment{
+			{Name: "first", Subsegments: []*Segment{{Name: "nested"}}},
+			{Name: "second"},
+		},
+	}
+
+	for _, name := range []string{"first", "second", "nested"} {
+		if FindSubsegment(seg, name) == nil {
+			t.Errorf("Subsegment '%s' should be found", name)
+		}
+	}
+
+	if FindSubsegment(seg, "missing") != nil {
+		t.Error("Subsegment 'missing' should not be found")
+	}
+}
+
+func TestAssertAnnotation(t *testing.T) {
+	seg := &Segment{
+		Name: "segment",
+		Annotations: map[string]interface{}{
+			"string": "value",
+			"number": float64(1),
+			"bool":   true,
+		},
+	}
+
+	AssertAnnotation(t, seg, "string", "value")
+	AssertAnnotation(t, seg, "number", 1)
+	AssertAnnotation(t, seg, "bool", true)
+}
@@ -0,0 +1,173 @@
+// Package xraytest provides an in-process X-Ray daemon and assertions for
+// testing instrumentation.
+package xraytest
+
+import (
+	"fmt"
+	"github.com/goguardian/aws-xray-go/daemon"
+	"github.com/goguardian/aws-xray-go/segment"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const maxPacketSize = 64 * 1024
+
+// Segment represents a segment or subsegment document received by the
+// daemon.
+type Segment = daemon.Document
+
+// Daemon represents a local UDP listener speaking the X-Ray daemon protocol
+// that records every segment it receives.
+type Daemon struct {
+	conn     *net.UDPConn
+	segments []*Segment
+	errors   []error
+	received chan struct{}
+	done     chan struct{}
+
+	sync.Mutex
+}
+
+// NewDaemon starts a daemon listening on a random local UDP port.
+func NewDaemon() (*Daemon, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Daemon{
+		conn:     conn,
+		received: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	go d.listen()
+
+	return d, nil
+}
+
+// StartDaemon starts a daemon and installs an emitter sending to it for the
+// duration of the test.
+func StartDaemon(t testing.TB) *Daemon {
+	d, err := NewDaemon()
+	if err != nil {
+		t.Fatalf("Error starting X-Ray test daemon: %s", err)
+	}
+
+	previous := segment.GetEmitter()
+	segment.SetEmitter(d.Emitter())
+
+	t.Cleanup(func() {
+		segment.SetEmitter(previous)
+		d.Close()
+	})
+
+	return d
+}
+
+// Address returns the UDP address of the daemon.
+func (d *Daemon) Address() string {
+	return d.conn.LocalAddr().String()
+}
+
+// Emitter returns a new emitter sending to the daemon.
+func (d *Daemon) Emitter() *segment.UDPEmitter {
+	emitter := segment.NewUDPEmitter()
+	emitter.SetDaemonAddress(d.Address())
+	return emitter
+}
+
+// Close stops the daemon.
+func (d *Daemon) Close() error {
+	err := d.conn.Close()
+	<-d.done
+	return err
+}
+
+func (d *Daemon) listen() {
+	defer close(d.done)
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		seg, err := ParsePacket(buf[:n])
+
+		d.Lock()
+		if err != nil {
+			d.errors = append(d.errors, err)
+		} else {
+			d.segments = append(d.segments, seg)
+		}
+		d.Unlock()
+
+		select {
+		case d.received <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ParsePacket decodes a packet in the daemon protocol, a header line followed
+// by a JSON document.
+func ParsePacket(packet []byte) (*Segment, error) {
+	return daemon.ParsePacket(packet)
+}
+
+// Segments returns all segments received in the order they were received.
+func (d *Daemon) Segments() []*Segment {
+	d.Lock()
+	defer d.Unlock()
+
+	segments := make([]*Segment, len(d.segments))
+	copy(segments, d.segments)
+
+	return segments
+}
+
+// Errors returns errors decoding received packets.
+func (d *Daemon) Errors() []error {
+	d.Lock()
+	defer d.Unlock()
+
+	errs := make([]error, len(d.errors))
+	copy(errs, d.errors)
+
+	return errs
+}
+
+// Reset discards all received segments and errors.
+func (d *Daemon) Reset() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.segments = nil
+	d.errors = nil
+}
+
+// WaitForSegment waits for a completed segment or subsegment document with
+// the name to be received.  In-progress documents are ignored.
+func (d *Daemon) WaitForSegment(name string, timeout time.Duration) (*Segment, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		for _, seg := range d.Segments() {
+			if seg.Name == name && !seg.InProgress {
+				return seg, nil
+			}
+		}
+
+		select {
+		case <-d.received:
+		case <-deadline.C:
+			return nil, fmt.Errorf("segment %q not received within %s",
+				name, timeout)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package xraytest
+
+import (
+	"github.com/goguardian/aws-xray-go/segment"
+	"testing"
+	"time"
+)
+
+func TestDaemon(t *testing.T) {
+	daemon := StartDaemon(t)
+
+	seg := segment.New("segment", nil)
+	seg.Traced = true
+	seg.AddAnnotation("count", 3)
+	seg.AddNewSubsegment("subsegment").Close(nil, "")
+	seg.Close()
+
+	received, err := daemon.WaitForSegment("segment", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.ID != seg.ID || received.TraceID != seg.TraceID {
+		t.Error("Received segment should match the sent segment")
+	}
+
+	AssertSubsegment(t, received, "subsegment")
+	AssertAnnotation(t, received, "count", 3)
+
+	if len(daemon.Errors()) != 0 {
+		t.Errorf("Daemon should have no errors, got %v", daemon.Errors())
+	}
+
+	daemon.Reset()
+	if len(daemon.Segments()) != 0 {
+		t.Error("Daemon should have no segments after reset")
+	}
+
+	if _, err := daemon.WaitForSegment("missing", 10*time.Millisecond); err == nil {
+		t.Error("Waiting for a missing segment should error")
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	tests := []struct {
+		packet      string
+		expectError bool
+	}{
+		{packet: "{\"format\": \"json\", \"version\": 1}\n{\"name\": \"segment\"}"},
+		{packet: "{\"name\": \"segment\"}", expectError: true},
+		{packet: "{\"format\": \"xml\", \"version\": 1}\n{}", expectError: true},
+		{packet: "{\"format\": \"json\", \"version\": 1}\n{", expectError: true},
+	}
+
+	for _, test := range tests {
+		seg, err := ParsePacket([]byte(test.packet))
+		if test.expectError {
+			if err == nil {
+				t.Errorf("Expected error parsing %q", test.packet)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %s", test.packet, err)
+			continue
+		}
+
+		if seg.Name != "segment" {
+			t.Errorf("Expected name 'segment', got '%s'", seg.Name)
+		}
+	}
+}